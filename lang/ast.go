@@ -68,6 +68,7 @@ func (sb StmtBlock) String() string {
 }
 
 func (sb StmtBlock) isNode() {}
+func (sb StmtBlock) isStmt() {}
 
 // UseStmt describes a file or module import
 type UseStmt struct {
@@ -106,10 +107,11 @@ func (s UseFilter) Start() Loc     { return s.Name.Start() }
 func (s UseFilter) String() string { return s.Name.String() }
 func (s UseFilter) isNode()        {}
 
-// PubStmt describes a file or module import
+// PubStmt describes a declaration that is exported to dependent modules. The
+// exported statement is either a *DeclarationStmt, a *TypeStmt or an *EnumStmt
 type PubStmt struct {
 	Tok  token
-	Stmt *DeclarationStmt
+	Stmt Stmt
 }
 
 // Start returns a location that this node can be considered to start at
@@ -118,31 +120,145 @@ func (s PubStmt) String() string { return fmt.Sprintf("(pub %s)", s.Stmt) }
 func (s PubStmt) isNode()        {}
 func (s PubStmt) isStmt()        {}
 
-// IfStmt describes a condition expression and an associated clause
+// IfStmt describes a condition expression and an associated clause. The
+// optional else branch is either a *StmtBlock or another *IfStmt
 type IfStmt struct {
 	Tok    token
 	Cond   Expr
 	Clause *StmtBlock
+	Else   Stmt
 }
 
 // Start returns a location that this node can be considered to start at
-func (is IfStmt) Start() Loc     { return is.Tok.Loc }
-func (is IfStmt) String() string { return fmt.Sprintf("(if %s %s)", is.Cond, is.Clause) }
-func (is IfStmt) isNode()        {}
-func (is IfStmt) isStmt()        {}
+func (is IfStmt) Start() Loc { return is.Tok.Loc }
 
-// DeclarationStmt describes the declaration and assignment of a variable
+func (is IfStmt) String() string {
+	if is.Else != nil {
+		return fmt.Sprintf("(if %s %s else %s)", is.Cond, is.Clause, is.Else)
+	}
+
+	return fmt.Sprintf("(if %s %s)", is.Cond, is.Clause)
+}
+
+func (is IfStmt) isNode() {}
+func (is IfStmt) isStmt() {}
+
+// WhileStmt describes a condition expression and a clause that is repeated
+// for as long as the condition holds
+type WhileStmt struct {
+	Tok    token
+	Cond   Expr
+	Clause *StmtBlock
+}
+
+// Start returns a location that this node can be considered to start at
+func (ws WhileStmt) Start() Loc     { return ws.Tok.Loc }
+func (ws WhileStmt) String() string { return fmt.Sprintf("(while %s %s)", ws.Cond, ws.Clause) }
+func (ws WhileStmt) isNode()        {}
+func (ws WhileStmt) isStmt()        {}
+
+// BreakStmt describes an early exit from the innermost loop
+type BreakStmt struct {
+	Tok token
+}
+
+// Start returns a location that this node can be considered to start at
+func (bs BreakStmt) Start() Loc     { return bs.Tok.Loc }
+func (bs BreakStmt) String() string { return "(break)" }
+func (bs BreakStmt) isNode()        {}
+func (bs BreakStmt) isStmt()        {}
+
+// ContinueStmt describes a jump to the next iteration of the innermost loop
+type ContinueStmt struct {
+	Tok token
+}
+
+// Start returns a location that this node can be considered to start at
+func (cs ContinueStmt) Start() Loc     { return cs.Tok.Loc }
+func (cs ContinueStmt) String() string { return "(continue)" }
+func (cs ContinueStmt) isNode()        {}
+func (cs ContinueStmt) isStmt()        {}
+
+// DeclarationStmt describes the declaration and assignment of a variable. If
+// the statement starts with the CONST keyword the variable is read-only
 type DeclarationStmt struct {
 	Tok  token
 	Name *IdentExpr
+	Note TypeNote
 	Expr Expr
 }
 
 // Start returns a location that this node can be considered to start at
-func (ds DeclarationStmt) Start() Loc     { return ds.Tok.Loc }
-func (ds DeclarationStmt) String() string { return fmt.Sprintf("(let %s %s)", ds.Name, ds.Expr) }
-func (ds DeclarationStmt) isNode()        {}
-func (ds DeclarationStmt) isStmt()        {}
+func (ds DeclarationStmt) Start() Loc { return ds.Tok.Loc }
+func (ds DeclarationStmt) isNode()    {}
+func (ds DeclarationStmt) isStmt()    {}
+
+// IsConst returns true if the declared variable cannot be reassigned
+func (ds DeclarationStmt) IsConst() bool { return ds.Tok.Type == tokConst }
+
+func (ds DeclarationStmt) String() string {
+	keyword := "let"
+	if ds.IsConst() {
+		keyword = "const"
+	}
+	if ds.Note != nil {
+		return fmt.Sprintf("(%s %s:%s %s)", keyword, ds.Name, ds.Note, ds.Expr)
+	}
+	return fmt.Sprintf("(%s %s %s)", keyword, ds.Name, ds.Expr)
+}
+
+// TypeStmt describes a name bound to a type
+type TypeStmt struct {
+	Tok  token
+	Name *IdentExpr
+	Note TypeNote
+}
+
+// Start returns a location that this node can be considered to start at
+func (ts TypeStmt) Start() Loc     { return ts.Tok.Loc }
+func (ts TypeStmt) String() string { return fmt.Sprintf("(type %s %s)", ts.Name, ts.Note) }
+func (ts TypeStmt) isNode()        {}
+func (ts TypeStmt) isStmt()        {}
+
+// EnumStmt describes a named type whose values are one of several variants,
+// each of which can carry a fixed list of values
+type EnumStmt struct {
+	Tok      token
+	Name     *IdentExpr
+	Variants []EnumVariant
+}
+
+// Start returns a location that this node can be considered to start at
+func (es EnumStmt) Start() Loc { return es.Tok.Loc }
+func (es EnumStmt) isNode()    {}
+func (es EnumStmt) isStmt()    {}
+
+func (es EnumStmt) String() string {
+	out := fmt.Sprintf("(enum %s", es.Name)
+	for _, variant := range es.Variants {
+		out += " " + variant.String()
+	}
+	return out + ")"
+}
+
+// EnumVariant describes one of the variants of an enum and the types of the
+// values it carries
+type EnumVariant struct {
+	Name    *IdentExpr
+	Payload []TypeNote
+}
+
+func (ev EnumVariant) String() string {
+	if len(ev.Payload) == 0 {
+		return ev.Name.String()
+	}
+
+	var notes []string
+	for _, note := range ev.Payload {
+		notes = append(notes, note.String())
+	}
+	return fmt.Sprintf("%s(%s)", ev.Name, strings.Join(notes, " "))
+}
 
 // ReturnStmt describes a return keyword and an optional returned expression.
 type ReturnStmt struct {
@@ -260,6 +376,20 @@ func (ti TypeNoteIdent) String() string { return ti.Name }
 func (ti TypeNoteIdent) isNode()        {}
 func (ti TypeNoteIdent) isType()        {}
 
+// TypeNoteQualified describes a named reference to a type exported by
+// another module
+type TypeNoteQualified struct {
+	Tok    token
+	Module string
+	Name   string
+}
+
+// Start returns a location that this node can be considered to start at
+func (tq TypeNoteQualified) Start() Loc     { return tq.Tok.Loc }
+func (tq TypeNoteQualified) String() string { return fmt.Sprintf("%s.%s", tq.Module, tq.Name) }
+func (tq TypeNoteQualified) isNode()        {}
+func (tq TypeNoteQualified) isType()        {}
+
 // TypeNoteList describes a list type
 type TypeNoteList struct {
 	Tok   token
@@ -272,6 +402,36 @@ func (tl TypeNoteList) String() string { return fmt.Sprintf("[%s]", tl.Child) }
 func (tl TypeNoteList) isNode()        {}
 func (tl TypeNoteList) isType()        {}
 
+// TypeNoteStruct describes a struct type with named fields
+type TypeNoteStruct struct {
+	Tok    token
+	Fields []TypeNoteField
+}
+
+// TypeNoteField describes a single named field within a struct type
+type TypeNoteField struct {
+	Name *IdentExpr
+	Note TypeNote
+}
+
+// Start returns a location that this node can be considered to start at
+func (ts TypeNoteStruct) Start() Loc { return ts.Tok.Loc }
+
+func (ts TypeNoteStruct) String() string {
+	out := "{"
+	for i, field := range ts.Fields {
+		if i > 0 {
+			out += " "
+		}
+		out += fmt.Sprintf("%s:%s", field.Name, field.Note)
+	}
+	out += "}"
+	return out
+}
+
+func (ts TypeNoteStruct) isNode() {}
+func (ts TypeNoteStruct) isType() {}
+
 // TypeNoteOptional describes a list type
 type TypeNoteOptional struct {
 	Tok   token
@@ -294,17 +454,29 @@ type Expr interface {
 
 // FunctionExpr describes a function's entire type signature and body
 type FunctionExpr struct {
-	Tok    token
-	Params []*FunctionParam
-	Ret    TypeNote
-	Block  *StmtBlock
+	Tok        token
+	TypeParams []*IdentExpr
+	Params     []*FunctionParam
+	Ret        TypeNote
+	Block      *StmtBlock
 }
 
 // Start returns a location that this node can be considered to start at
 func (fe FunctionExpr) Start() Loc { return fe.Tok.Loc }
 
 func (fe FunctionExpr) String() string {
-	out := "(fn ("
+	out := "(fn "
+	if len(fe.TypeParams) > 0 {
+		out += "<"
+		for i, param := range fe.TypeParams {
+			if i > 0 {
+				out += " "
+			}
+			out += param.String()
+		}
+		out += "> "
+	}
+	out += "("
 	for i, param := range fe.Params {
 		if i > 0 {
 			out += " "
@@ -380,6 +552,19 @@ func (ae AssignExpr) String() string { return fmt.Sprintf("(= %s %s)", ae.Left,
 func (ae AssignExpr) isNode()        {}
 func (ae AssignExpr) isExpr()        {}
 
+// FieldAssignExpr describes the replacement of a single struct field
+type FieldAssignExpr struct {
+	Tok   token
+	Left  *AccessExpr
+	Right Expr
+}
+
+// Start returns a location that this node can be considered to start at
+func (fe FieldAssignExpr) Start() Loc     { return fe.Left.Start() }
+func (fe FieldAssignExpr) String() string { return fmt.Sprintf("(= %s %s)", fe.Left, fe.Right) }
+func (fe FieldAssignExpr) isNode()        {}
+func (fe FieldAssignExpr) isExpr()        {}
+
 // ListExpr describes a listeral list constructor
 type ListExpr struct {
 	Tok      token
@@ -410,6 +595,123 @@ func (se SubscriptExpr) String() string { return fmt.Sprintf("%s[%s]", se.ListLi
 func (se SubscriptExpr) isNode()        {}
 func (se SubscriptExpr) isExpr()        {}
 
+// CastExpr describes the conversion of a value to a type that is checked at
+// runtime. The result is none if the value doesn't have the type
+type CastExpr struct {
+	Tok  token
+	Expr Expr
+	Note TypeNote
+}
+
+// Start returns a location that this node can be considered to start at
+func (ce CastExpr) Start() Loc     { return ce.Expr.Start() }
+func (ce CastExpr) String() string { return fmt.Sprintf("(as %s %s)", ce.Expr, ce.Note) }
+func (ce CastExpr) isNode()        {}
+func (ce CastExpr) isExpr()        {}
+
+// IsExpr describes a runtime test of whether a value has a particular type
+type IsExpr struct {
+	Tok  token
+	Expr Expr
+	Note TypeNote
+}
+
+// Start returns a location that this node can be considered to start at
+func (ie IsExpr) Start() Loc     { return ie.Expr.Start() }
+func (ie IsExpr) String() string { return fmt.Sprintf("(is %s %s)", ie.Expr, ie.Note) }
+func (ie IsExpr) isNode()        {}
+func (ie IsExpr) isExpr()        {}
+
+// TypeofExpr describes the name of the runtime type of a value
+type TypeofExpr struct {
+	Tok  token
+	Expr Expr
+}
+
+// Start returns a location that this node can be considered to start at
+func (te TypeofExpr) Start() Loc     { return te.Tok.Loc }
+func (te TypeofExpr) String() string { return fmt.Sprintf("(typeof %s)", te.Expr) }
+func (te TypeofExpr) isNode()        {}
+func (te TypeofExpr) isExpr()        {}
+
+// StructExpr describes a literal struct constructor
+type StructExpr struct {
+	Tok    token
+	Fields []StructField
+}
+
+// StructField describes a single named field within a struct constructor
+type StructField struct {
+	Name *IdentExpr
+	Expr Expr
+}
+
+// Start returns a location that this node can be considered to start at
+func (se StructExpr) Start() Loc { return se.Tok.Loc }
+func (se StructExpr) String() string {
+	out := "{ "
+	for _, field := range se.Fields {
+		out += fmt.Sprintf("%s:%s ", field.Name, field.Expr)
+	}
+	return out + "}"
+}
+func (se StructExpr) isNode() {}
+func (se StructExpr) isExpr() {}
+
+// MatchExpr describes the selection of an expression based on which enum
+// variant a value is
+type MatchExpr struct {
+	Tok     token
+	Subject Expr
+	Arms    []*MatchArm
+}
+
+// Start returns a location that this node can be considered to start at
+func (me MatchExpr) Start() Loc { return me.Tok.Loc }
+func (me MatchExpr) isNode()    {}
+func (me MatchExpr) isExpr()    {}
+
+func (me MatchExpr) String() string {
+	out := fmt.Sprintf("(match %s", me.Subject)
+	for _, arm := range me.Arms {
+		out += " " + arm.String()
+	}
+	return out + ")"
+}
+
+// MatchArm describes an expression that is evaluated when the value being
+// matched is the named variant. The values carried by the variant are bound
+// to the arm's bindings. An arm without a variant matches any value
+type MatchArm struct {
+	Tok      token
+	Variant  *IdentExpr
+	Bindings []*IdentExpr
+	Expr     Expr
+}
+
+// Start returns a location that this node can be considered to start at
+func (ma MatchArm) Start() Loc { return ma.Tok.Loc }
+func (ma MatchArm) isNode()    {}
+
+// IsWildcard returns true if the arm matches every variant
+func (ma MatchArm) IsWildcard() bool { return ma.Variant == nil }
+
+func (ma MatchArm) String() string {
+	if ma.IsWildcard() {
+		return fmt.Sprintf("(else %s)", ma.Expr)
+	}
+
+	if len(ma.Bindings) == 0 {
+		return fmt.Sprintf("(%s %s)", ma.Variant, ma.Expr)
+	}
+
+	var names []string
+	for _, binding := range ma.Bindings {
+		names = append(names, binding.Name)
+	}
+	return fmt.Sprintf("(%s(%s) %s)", ma.Variant, strings.Join(names, " "), ma.Expr)
+}
+
 // AccessExpr uses dot notation to retrieve a sub-object
 type AccessExpr struct {
 	Left  Expr
@@ -484,6 +786,18 @@ func (se StringExpr) String() string { return fmt.Sprintf("\"%s\"", se.Val) }
 func (se StringExpr) isNode()        {}
 func (se StringExpr) isExpr()        {}
 
+// FloatExpr describes a floating-point literal
+type FloatExpr struct {
+	Tok token
+	Val float64
+}
+
+// Start returns a location that this node can be considered to start at
+func (fe FloatExpr) Start() Loc     { return fe.Tok.Loc }
+func (fe FloatExpr) String() string { return formatFloat(fe.Val) }
+func (fe FloatExpr) isNode()        {}
+func (fe FloatExpr) isExpr()        {}
+
 // NumberExpr describes a string literal
 type NumberExpr struct {
 	Tok token
@@ -514,6 +828,17 @@ func (be BooleanExpr) String() string {
 func (be BooleanExpr) isNode() {}
 func (be BooleanExpr) isExpr() {}
 
+// NoneExpr describes the absence of an optional value
+type NoneExpr struct {
+	Tok token
+}
+
+// Start returns a location that this node can be considered to start at
+func (ne NoneExpr) Start() Loc     { return ne.Tok.Loc }
+func (ne NoneExpr) String() string { return "none" }
+func (ne NoneExpr) isNode()        {}
+func (ne NoneExpr) isExpr()        {}
+
 func indentBlock(indent string, source string) string {
 	lines := strings.Split(source, "\n")
 	for i, line := range lines {
@@ -10,8 +10,8 @@ func TestProgram(t *testing.T) {
 	(AST{}).isNode()
 
 	prog := AST{[]Stmt{
-		DeclarationStmt{nop, &IdentExpr{nop, "a"}, &NumberExpr{nop, 123}},
-		DeclarationStmt{nop, &IdentExpr{nop, "b"}, &NumberExpr{nop, 456}},
+		DeclarationStmt{nop, &IdentExpr{nop, "a"}, nil, &NumberExpr{nop, 123}},
+		DeclarationStmt{nop, &IdentExpr{nop, "b"}, nil, &NumberExpr{nop, 456}},
 	}}
 	expectASTString(t, prog, "(let a 123)\n(let b 456)")
 
@@ -23,8 +23,8 @@ func TestStmtBlock(t *testing.T) {
 	(StmtBlock{}).isNode()
 
 	block := StmtBlock{nop, []Stmt{
-		DeclarationStmt{nop, &IdentExpr{nop, "a"}, &NumberExpr{nop, 123}},
-		DeclarationStmt{nop, &IdentExpr{nop, "b"}, &NumberExpr{nop, 456}},
+		DeclarationStmt{nop, &IdentExpr{nop, "a"}, nil, &NumberExpr{nop, 123}},
+		DeclarationStmt{nop, &IdentExpr{nop, "b"}, nil, &NumberExpr{nop, 456}},
 	}, nop}
 	expectASTString(t, block, "{\n  (let a 123)\n  (let b 456)}")
 }
@@ -61,6 +61,57 @@ func TestPubStmt(t *testing.T) {
 	decl := &DeclarationStmt{Name: &IdentExpr{Name: "foo"}, Expr: &IdentExpr{Name: "bar"}}
 	expectASTString(t, PubStmt{Stmt: decl}, `(pub (let foo bar))`)
 	expectStart(t, PubStmt{Stmt: decl}, 0, 0)
+
+	typ := &TypeStmt{Name: &IdentExpr{Name: "Foo"}, Note: TypeNoteIdent{nop, "Int"}}
+	expectASTString(t, PubStmt{Stmt: typ}, `(pub (type Foo Int))`)
+}
+
+func TestTypeStmt(t *testing.T) {
+	(TypeStmt{}).isNode()
+	(TypeStmt{}).isStmt()
+
+	note := TypeNoteStruct{nop, []TypeNoteField{{&IdentExpr{nop, "x"}, TypeNoteIdent{nop, "Int"}}}}
+	stmt := TypeStmt{nop, &IdentExpr{nop, "Point"}, note}
+	expectASTString(t, stmt, `(type Point {x:Int})`)
+	expectStart(t, stmt, 0, 0)
+}
+
+func TestEnumStmt(t *testing.T) {
+	(EnumStmt{}).isNode()
+	(EnumStmt{}).isStmt()
+
+	stmt := EnumStmt{nop, &IdentExpr{nop, "Shape"}, []EnumVariant{
+		{&IdentExpr{nop, "Circle"}, []TypeNote{TypeNoteIdent{nop, "Float"}}},
+		{&IdentExpr{nop, "Rect"}, []TypeNote{TypeNoteIdent{nop, "Float"}, TypeNoteIdent{nop, "Float"}}},
+		{&IdentExpr{nop, "Empty"}, nil},
+	}}
+	expectASTString(t, stmt, "(enum Shape Circle(Float) Rect(Float Float) Empty)")
+	expectStart(t, stmt, 0, 0)
+}
+
+func TestMatchExpr(t *testing.T) {
+	(MatchExpr{}).isNode()
+	(MatchExpr{}).isExpr()
+	(MatchArm{}).isNode()
+
+	expr := MatchExpr{nop, &IdentExpr{nop, "s"}, []*MatchArm{
+		{nop, &IdentExpr{nop, "Rect"}, []*IdentExpr{{nop, "w"}, {nop, "h"}}, &IdentExpr{nop, "w"}},
+		{nop, &IdentExpr{nop, "Empty"}, nil, &NumberExpr{nop, 0}},
+		{nop, nil, nil, &NumberExpr{nop, 1}},
+	}}
+	expectASTString(t, expr, "(match s (Rect(w h) w) (Empty 0) (else 1))")
+	expectStart(t, expr, 0, 0)
+	expectBool(t, expr.Arms[0].IsWildcard(), false)
+	expectBool(t, expr.Arms[2].IsWildcard(), true)
+}
+
+func TestTypeNoteQualified(t *testing.T) {
+	(TypeNoteQualified{}).isNode()
+	(TypeNoteQualified{}).isType()
+
+	note := TypeNoteQualified{nop, "geo", "Point"}
+	expectASTString(t, note, `geo.Point`)
+	expectStart(t, note, 0, 0)
 }
 
 func TestIfStmt(t *testing.T) {
@@ -68,14 +119,47 @@ func TestIfStmt(t *testing.T) {
 	(IfStmt{}).isStmt()
 
 	block := &StmtBlock{nop, []Stmt{}, nop}
-	expectASTString(t, IfStmt{nop, &BooleanExpr{nop, true}, block}, "(if true {})")
+	expectASTString(t, IfStmt{nop, &BooleanExpr{nop, true}, block, nil}, "(if true {})")
+	expectASTString(t, IfStmt{nop, &BooleanExpr{nop, true}, block, block}, "(if true {} else {})")
+
+	alt := &IfStmt{nop, &BooleanExpr{nop, false}, block, block}
+	expectASTString(t, IfStmt{nop, &BooleanExpr{nop, true}, block, alt}, "(if true {} else (if false {} else {}))")
+}
+
+func TestWhileStmt(t *testing.T) {
+	(WhileStmt{}).isNode()
+	(WhileStmt{}).isStmt()
+
+	block := &StmtBlock{nop, []Stmt{&BreakStmt{nop}}, nop}
+	expectASTString(t, WhileStmt{nop, &BooleanExpr{nop, true}, block}, "(while true {\n  (break)})")
+}
+
+func TestBreakStmt(t *testing.T) {
+	(BreakStmt{}).isNode()
+	(BreakStmt{}).isStmt()
+
+	expectASTString(t, BreakStmt{nop}, "(break)")
+	expectStart(t, BreakStmt{makeTok(3, 4)}, 3, 4)
+}
+
+func TestContinueStmt(t *testing.T) {
+	(ContinueStmt{}).isNode()
+	(ContinueStmt{}).isStmt()
+
+	expectASTString(t, ContinueStmt{nop}, "(continue)")
+	expectStart(t, ContinueStmt{makeTok(3, 4)}, 3, 4)
 }
 
 func TestDeclarationStmt(t *testing.T) {
 	(DeclarationStmt{}).isNode()
 	(DeclarationStmt{}).isStmt()
 
-	expectASTString(t, DeclarationStmt{nop, &IdentExpr{nop, "a"}, &NumberExpr{nop, 123}}, "(let a 123)")
+	expectASTString(t, DeclarationStmt{nop, &IdentExpr{nop, "a"}, nil, &NumberExpr{nop, 123}}, "(let a 123)")
+	expectASTString(t, DeclarationStmt{nop, &IdentExpr{nop, "a"}, TypeNoteIdent{nop, "Int"}, &NumberExpr{nop, 123}}, "(let a:Int 123)")
+
+	tok := token{tokConst, "const", Loc{1, 1}}
+	expectASTString(t, DeclarationStmt{tok, &IdentExpr{nop, "a"}, nil, &NumberExpr{nop, 123}}, "(const a 123)")
+	expectBool(t, DeclarationStmt{tok, &IdentExpr{nop, "a"}, nil, &NumberExpr{nop, 123}}.IsConst(), true)
 }
 
 func TestReturnStmt(t *testing.T) {
@@ -141,6 +225,17 @@ func TestTypeNoteList(t *testing.T) {
 	expectASTString(t, TypeNoteList{nop, TypeNoteIdent{nop, "Int"}}, "[Int]")
 }
 
+func TestTypeNoteStruct(t *testing.T) {
+	(TypeNoteStruct{}).isNode()
+	(TypeNoteStruct{}).isType()
+
+	expectASTString(t, TypeNoteStruct{nop, nil}, "{}")
+	expectASTString(t, TypeNoteStruct{nop, []TypeNoteField{
+		{&IdentExpr{nop, "x"}, TypeNoteIdent{nop, "Int"}},
+		{&IdentExpr{nop, "y"}, TypeNoteList{nop, TypeNoteIdent{nop, "Str"}}},
+	}}, "{x:Int y:[Str]}")
+}
+
 func TestTypeNoteOptional(t *testing.T) {
 	(TypeNoteOptional{}).isNode()
 	(TypeNoteOptional{}).isType()
@@ -159,10 +254,13 @@ func TestFunctionExpr(t *testing.T) {
 	}
 	ret := TypeNoteIdent{nop, "Str"}
 	block := &StmtBlock{nop, []Stmt{
-		&DeclarationStmt{nop, &IdentExpr{nop, "z"}, &NumberExpr{nop, 123}},
+		&DeclarationStmt{nop, &IdentExpr{nop, "z"}, nil, &NumberExpr{nop, 123}},
 	}, nop}
 
-	expectASTString(t, &FunctionExpr{nop, params, ret, block}, "(fn (x:Int y):Str {\n  (let z 123)})")
+	expectASTString(t, &FunctionExpr{nop, nil, params, ret, block}, "(fn (x:Int y):Str {\n  (let z 123)})")
+
+	typeParams := []*IdentExpr{{nop, "T"}, {nop, "U"}}
+	expectASTString(t, &FunctionExpr{nop, typeParams, params, ret, block}, "(fn <T U> (x:Int y):Str {\n  (let z 123)})")
 }
 
 func TestDispatchExpr(t *testing.T) {
@@ -186,6 +284,25 @@ func TestAssignExpr(t *testing.T) {
 	expectASTString(t, AssignExpr{nop, &IdentExpr{nop, "a"}, &IdentExpr{nop, "b"}}, "(= a b)")
 }
 
+func TestFieldAssignExpr(t *testing.T) {
+	(FieldAssignExpr{}).isNode()
+	(FieldAssignExpr{}).isExpr()
+
+	access := &AccessExpr{&IdentExpr{nop, "a"}, &IdentExpr{nop, "x"}}
+	expectASTString(t, FieldAssignExpr{nop, access, &IdentExpr{nop, "b"}}, "(= (a).x b)")
+}
+
+func TestStructExpr(t *testing.T) {
+	(StructExpr{}).isNode()
+	(StructExpr{}).isExpr()
+
+	expectASTString(t, StructExpr{nop, nil}, "{ }")
+	expectASTString(t, StructExpr{nop, []StructField{
+		{&IdentExpr{nop, "x"}, &NumberExpr{nop, 1}},
+		{&IdentExpr{nop, "y"}, &IdentExpr{nop, "a"}},
+	}}, "{ x:1 y:a }")
+}
+
 func TestListExpr(t *testing.T) {
 	(ListExpr{}).isNode()
 	(ListExpr{}).isExpr()
@@ -222,6 +339,31 @@ func TestUnaryExpr(t *testing.T) {
 	expectASTString(t, UnaryExpr{"+", nop, &NumberExpr{nop, 123}}, "(+ 123)")
 }
 
+func TestCastExpr(t *testing.T) {
+	(CastExpr{}).isNode()
+	(CastExpr{}).isExpr()
+
+	expr := CastExpr{nop, &IdentExpr{token{tokIdent, "a", Loc{2, 3}}, "a"}, TypeNoteIdent{nop, "Int"}}
+	expectASTString(t, expr, "(as a Int)")
+	expectStart(t, expr, 2, 3)
+}
+
+func TestIsExpr(t *testing.T) {
+	(IsExpr{}).isNode()
+	(IsExpr{}).isExpr()
+
+	expr := IsExpr{nop, &IdentExpr{token{tokIdent, "a", Loc{2, 3}}, "a"}, TypeNoteIdent{nop, "Int"}}
+	expectASTString(t, expr, "(is a Int)")
+	expectStart(t, expr, 2, 3)
+}
+
+func TestTypeofExpr(t *testing.T) {
+	(TypeofExpr{}).isNode()
+	(TypeofExpr{}).isExpr()
+
+	expectASTString(t, TypeofExpr{nop, &IdentExpr{nop, "a"}}, "(typeof a)")
+}
+
 func TestIdentExpr(t *testing.T) {
 	(IdentExpr{}).isNode()
 	(IdentExpr{}).isExpr()
@@ -236,6 +378,15 @@ func TestStringExpr(t *testing.T) {
 	expectASTString(t, StringExpr{nop, "abc"}, "\"abc\"")
 }
 
+func TestFloatExpr(t *testing.T) {
+	(FloatExpr{}).isNode()
+	(FloatExpr{}).isExpr()
+
+	expectASTString(t, &FloatExpr{nop, 1.5}, "1.5")
+	expectASTString(t, &FloatExpr{nop, 2}, "2.0")
+	expectASTString(t, &FloatExpr{nop, 1e21}, "1e+21")
+}
+
 func TestNumberExpr(t *testing.T) {
 	(NumberExpr{}).isNode()
 	(NumberExpr{}).isExpr()
@@ -251,6 +402,13 @@ func TestBooleanExpr(t *testing.T) {
 	expectASTString(t, BooleanExpr{nop, false}, "false")
 }
 
+func TestNoneExpr(t *testing.T) {
+	(NoneExpr{}).isNode()
+	(NoneExpr{}).isExpr()
+
+	expectASTString(t, NoneExpr{nop}, "none")
+}
+
 func TestIndentBlock(t *testing.T) {
 	source := "foo\nbar\n  baz"
 	exp := "...foo\n...bar\n...  baz"
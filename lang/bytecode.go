@@ -1,9 +1,35 @@
 package lang
 
-import "fmt"
-
+import (
+	"fmt"
+	"plaid/lang/types"
+	"strings"
+)
+
+// Bytecode is a sequence of instructions. Positions is the position table,
+// the instruction at each address was produced by the source code at the same
+// index in Positions
 type Bytecode struct {
 	Instructions []Instr
+	Positions    []Position
+}
+
+// Position links an instruction back to the module and the location of the
+// syntax node that produced it
+type Position struct {
+	Module string
+	Loc    Loc
+}
+
+func (p Position) String() string { return fmt.Sprintf("%s%s", p.Module, p.Loc) }
+
+// Position returns the source position of the instruction at the given
+// address and false if the instruction wasn't produced by a syntax node
+func (b *Bytecode) Position(addr Address) (Position, bool) {
+	if int(addr) >= len(b.Positions) || b.Positions[addr] == (Position{}) {
+		return Position{}, false
+	}
+	return b.Positions[addr], true
 }
 
 func (b *Bytecode) nextInstrPtr() Address {
@@ -13,21 +39,36 @@ func (b *Bytecode) nextInstrPtr() Address {
 func (b *Bytecode) write(instr Instr) Address {
 	ip := b.nextInstrPtr()
 	b.Instructions = append(b.Instructions, instr)
+	b.Positions = append(b.Positions, Position{})
 	return ip
 }
 
 func (b *Bytecode) append(blob Bytecode) Address {
 	offset := b.nextInstrPtr()
-	for _, instr := range blob.Instructions {
+	for addr, instr := range blob.Instructions {
 		if jump, ok := instr.(InstrAddressed); ok {
 			b.Instructions = append(b.Instructions, jump.offset(offset))
 		} else {
 			b.Instructions = append(b.Instructions, instr)
 		}
+
+		pos, _ := blob.Position(Address(addr))
+		b.Positions = append(b.Positions, pos)
 	}
 	return b.nextInstrPtr()
 }
 
+// locate attributes every instruction that doesn't have a position yet to the
+// given source location. Since nested nodes are located first, instructions
+// end up attributed to the innermost node that produced them
+func (b *Bytecode) locate(module string, loc Loc) {
+	for addr := range b.Instructions {
+		if _, ok := b.Position(Address(addr)); ok == false {
+			b.Positions[addr] = Position{module, loc}
+		}
+	}
+}
+
 func (b *Bytecode) overwrite(addr Address, instr Instr) {
 	b.Instructions[addr] = instr
 }
@@ -42,6 +83,23 @@ func (b *Bytecode) String() (out string) {
 	return out
 }
 
+// Disassemble prints each instruction along with the source position that
+// produced it
+func (b *Bytecode) Disassemble() (out string) {
+	for i, instr := range b.Instructions {
+		if i > 0 {
+			out += "\n"
+		}
+
+		line := fmt.Sprintf("%s %s", Address(i), instr)
+		if pos, ok := b.Position(Address(i)); ok {
+			line = fmt.Sprintf("%-32s ; %s", line, pos)
+		}
+		out += line
+	}
+	return out
+}
+
 func sprintfArgs(name string, args ...interface{}) (out string) {
 	if len(args) == 0 {
 		return name
@@ -108,6 +166,21 @@ func (i InstrJumpFalse) String() string                       { return sprintfAr
 func (i InstrJumpFalse) offset(offset Address) InstrAddressed { return InstrJumpFalse{i.addr + offset} }
 func (i InstrJumpFalse) isInstr()                             {}
 
+// instrPendingBreak is a placeholder for a jump to the end of the innermost
+// loop. The placeholder is replaced once the loop has been fully compiled.
+type instrPendingBreak struct{}
+
+func (i instrPendingBreak) String() string { return "break" }
+func (i instrPendingBreak) isInstr()       {}
+
+// instrPendingContinue is a placeholder for a jump to the start of the
+// innermost loop. The placeholder is replaced once the loop has been fully
+// compiled.
+type instrPendingContinue struct{}
+
+func (i instrPendingContinue) String() string { return "continue" }
+func (i instrPendingContinue) isInstr()       {}
+
 type InstrPush struct {
 	Val Object
 }
@@ -125,6 +198,7 @@ type InstrCopy struct{}
 func (i InstrCopy) String() string { return "copy" }
 func (i InstrCopy) isInstr()       {}
 
+// InstrReserve adds a slot for the named variable to the current frame
 type InstrReserve struct {
 	Name string
 }
@@ -132,11 +206,15 @@ type InstrReserve struct {
 func (i InstrReserve) String() string { return sprintfArgs("alloc", i.Name) }
 func (i InstrReserve) isInstr()       {}
 
+// InstrStore pops a value and stores it in the slot Index of the frame Depth
+// frames above the current frame
 type InstrStore struct {
-	Name string
+	Name  string
+	Depth int
+	Index int
 }
 
-func (i InstrStore) String() string { return sprintfArgs("store", i.Name) }
+func (i InstrStore) String() string { return sprintfArgs("store", i.Name, i.Depth, i.Index) }
 func (i InstrStore) isInstr()       {}
 
 type InstrLoadAttr struct {
@@ -156,11 +234,15 @@ type InstrLoadMod struct{}
 func (i InstrLoadMod) String() string { return "mod" }
 func (i InstrLoadMod) isInstr()       {}
 
+// InstrLoad pushes the value in the slot Index of the frame Depth frames
+// above the current frame
 type InstrLoad struct {
-	Name string
+	Name  string
+	Depth int
+	Index int
 }
 
-func (i InstrLoad) String() string { return sprintfArgs("load", i.Name) }
+func (i InstrLoad) String() string { return sprintfArgs("load", i.Name, i.Depth, i.Index) }
 func (i InstrLoad) isInstr()       {}
 
 type InstrDispatch struct {
@@ -170,6 +252,103 @@ type InstrDispatch struct {
 func (i InstrDispatch) String() string { return sprintfArgs("call", i.args) }
 func (i InstrDispatch) isInstr()       {}
 
+type InstrCreateList struct {
+	length int
+}
+
+func (i InstrCreateList) String() string { return sprintfArgs("list", i.length) }
+func (i InstrCreateList) isInstr()       {}
+
+type InstrCreateStruct struct {
+	names []string
+}
+
+func (i InstrCreateStruct) String() string {
+	return sprintfArgs("struct", strings.Join(i.names, " "))
+}
+func (i InstrCreateStruct) isInstr() {}
+
+type InstrCreateVariant struct {
+	Enum  string
+	Tag   string
+	Arity int
+}
+
+func (i InstrCreateVariant) String() string {
+	return sprintfArgs("variant", i.Enum, i.Tag, i.Arity)
+}
+func (i InstrCreateVariant) isInstr() {}
+
+// InstrJumpNotVariant pops an enum value and jumps if the value is not the
+// named variant
+type InstrJumpNotVariant struct {
+	Tag  string
+	addr Address
+}
+
+func (i InstrJumpNotVariant) String() string { return sprintfArgs("jmpnv", i.Tag, i.addr) }
+func (i InstrJumpNotVariant) isInstr()       {}
+
+func (i InstrJumpNotVariant) offset(offset Address) InstrAddressed {
+	return InstrJumpNotVariant{i.Tag, i.addr + offset}
+}
+
+// InstrUnpack pops an enum value and pushes each of the Arity values carried
+// by the variant, in order
+type InstrUnpack struct {
+	Arity int
+}
+
+func (i InstrUnpack) String() string { return sprintfArgs("unpack", i.Arity) }
+func (i InstrUnpack) isInstr()       {}
+
+// InstrEnterBlock gives the instructions that follow their own variables
+// until the matching InstrLeaveBlock
+type InstrEnterBlock struct{}
+
+func (i InstrEnterBlock) String() string { return "enter" }
+func (i InstrEnterBlock) isInstr()       {}
+
+type InstrLeaveBlock struct{}
+
+func (i InstrLeaveBlock) String() string { return "leave" }
+func (i InstrLeaveBlock) isInstr()       {}
+
+// InstrCast pops a value and pushes it back if it has the given type,
+// otherwise it pushes none
+type InstrCast struct {
+	Type types.Type
+}
+
+func (i InstrCast) String() string { return sprintfArgs("cast", i.Type) }
+func (i InstrCast) isInstr()       {}
+
+// InstrIsType pops a value and pushes true if the value has the given type
+type InstrIsType struct {
+	Type types.Type
+}
+
+func (i InstrIsType) String() string { return sprintfArgs("istype", i.Type) }
+func (i InstrIsType) isInstr()       {}
+
+// InstrTypeof pops a value and pushes the name of its runtime type
+type InstrTypeof struct{}
+
+func (i InstrTypeof) String() string { return "typeof" }
+func (i InstrTypeof) isInstr()       {}
+
+type InstrStoreAttr struct {
+	Name string
+}
+
+func (i InstrStoreAttr) String() string { return sprintfArgs("setattr", i.Name) }
+func (i InstrStoreAttr) isInstr()       {}
+
+type InstrSubscript struct{}
+
+func (i InstrSubscript) String() string { return "index" }
+func (i InstrSubscript) isInstr()       {}
+
 type InstrCreateClosure struct{}
 
 func (i InstrCreateClosure) String() string { return sprintfArgs("close") }
@@ -200,6 +379,36 @@ type InstrMul struct{}
 func (i InstrMul) String() string { return "mul" }
 func (i InstrMul) isInstr()       {}
 
+type InstrDiv struct{}
+
+func (i InstrDiv) String() string { return "div" }
+func (i InstrDiv) isInstr()       {}
+
+type InstrRem struct{}
+
+func (i InstrRem) String() string { return "rem" }
+func (i InstrRem) isInstr()       {}
+
+type InstrNeg struct{}
+
+func (i InstrNeg) String() string { return "neg" }
+func (i InstrNeg) isInstr()       {}
+
+type InstrNot struct{}
+
+func (i InstrNot) String() string { return "not" }
+func (i InstrNot) isInstr()       {}
+
+type InstrEquals struct{}
+
+func (i InstrEquals) String() string { return "cmpeq" }
+func (i InstrEquals) isInstr()       {}
+
+type InstrNotEquals struct{}
+
+func (i InstrNotEquals) String() string { return "cmpneq" }
+func (i InstrNotEquals) isInstr()       {}
+
 type InstrLT struct{}
 
 func (i InstrLT) String() string { return "cmplt" }
@@ -1,6 +1,50 @@
 package lang
 
-import "testing"
+import (
+	"plaid/lang/types"
+	"strings"
+	"testing"
+)
+
+func TestBytecodePositions(t *testing.T) {
+	inner := Bytecode{}
+	inner.write(InstrPush{&ObjectInt{1}})
+	inner.locate("a.plaid", Loc{2, 3})
+	inner.write(InstrJump{0})
+	inner.locate("a.plaid", Loc{2, 1})
+
+	outer := Bytecode{}
+	outer.write(InstrNOP{})
+	outer.append(inner)
+	outer.write(InstrHalt{})
+
+	_, ok := outer.Position(0)
+	expectBool(t, ok, false)
+	pos, ok := outer.Position(1)
+	expectBool(t, ok, true)
+	expectString(t, pos.String(), "a.plaid(2:3)")
+	pos, _ = outer.Position(2)
+	expectString(t, pos.String(), "a.plaid(2:1)")
+	_, ok = outer.Position(3)
+	expectBool(t, ok, false)
+	_, ok = outer.Position(4)
+	expectBool(t, ok, false)
+
+	expectString(t, outer.Disassemble(), strings.Join([]string{
+		"0x0000 nop",
+		"0x0001 push    1                 ; a.plaid(2:3)",
+		"0x0002 jmp     0x0001            ; a.plaid(2:1)",
+		"0x0003 halt",
+	}, "\n"))
+
+	// Bytecode built without a position table has no positions
+	blob := Bytecode{Instructions: []Instr{InstrHalt{}}}
+	_, ok = blob.Position(0)
+	expectBool(t, ok, false)
+	outer.append(blob)
+	_, ok = outer.Position(4)
+	expectBool(t, ok, false)
+}
 
 func TestInstrHalt(t *testing.T) {
 	instr := InstrHalt{}
@@ -32,6 +76,18 @@ func TestInstrJumpFalse(t *testing.T) {
 	expectString(t, instr.String(), "jmpf    0x0064")
 }
 
+func TestInstrPendingBreak(t *testing.T) {
+	instr := instrPendingBreak{}
+	instr.isInstr()
+	expectString(t, instr.String(), "break")
+}
+
+func TestInstrPendingContinue(t *testing.T) {
+	instr := instrPendingContinue{}
+	instr.isInstr()
+	expectString(t, instr.String(), "continue")
+}
+
 func TestInstrPush(t *testing.T) {
 	instr := InstrPush{ObjectStr{"abc"}}
 	instr.isInstr()
@@ -57,9 +113,9 @@ func TestInstrReserve(t *testing.T) {
 }
 
 func TestInstrStore(t *testing.T) {
-	instr := InstrStore{Name: "foo"}
+	instr := InstrStore{Name: "foo", Depth: 1, Index: 2}
 	instr.isInstr()
-	expectString(t, instr.String(), "store   foo")
+	expectString(t, instr.String(), "store   foo     1       2")
 }
 
 func TestInstrLoadSelf(t *testing.T) {
@@ -69,9 +125,9 @@ func TestInstrLoadSelf(t *testing.T) {
 }
 
 func TestInstrLoad(t *testing.T) {
-	instr := InstrLoad{Name: "foo"}
+	instr := InstrLoad{Name: "foo", Depth: 1, Index: 2}
 	instr.isInstr()
-	expectString(t, instr.String(), "load    foo")
+	expectString(t, instr.String(), "load    foo     1       2")
 }
 
 func TestInstrDispatch(t *testing.T) {
@@ -92,6 +148,61 @@ func TestInstrReturn(t *testing.T) {
 	expectString(t, instr.String(), "ret")
 }
 
+func TestInstrCreateList(t *testing.T) {
+	instr := InstrCreateList{3}
+	instr.isInstr()
+	expectString(t, instr.String(), "list    3")
+}
+
+func TestInstrCreateStruct(t *testing.T) {
+	instr := InstrCreateStruct{[]string{"x", "y"}}
+	instr.isInstr()
+	expectString(t, instr.String(), "struct  x y")
+}
+
+func TestInstrCreateVariant(t *testing.T) {
+	instr := InstrCreateVariant{"Shape", "Rect", 2}
+	instr.isInstr()
+	expectString(t, instr.String(), "variant Shape   Rect    2")
+}
+
+func TestInstrJumpNotVariant(t *testing.T) {
+	instr := InstrJumpNotVariant{"Rect", 100}
+	instr.isInstr()
+	expectString(t, instr.String(), "jmpnv   Rect    0x0064")
+	expectString(t, instr.offset(2).String(), "jmpnv   Rect    0x0066")
+}
+
+func TestInstrUnpack(t *testing.T) {
+	instr := InstrUnpack{2}
+	instr.isInstr()
+	expectString(t, instr.String(), "unpack  2")
+}
+
+func TestInstrEnterBlock(t *testing.T) {
+	instr := InstrEnterBlock{}
+	instr.isInstr()
+	expectString(t, instr.String(), "enter")
+}
+
+func TestInstrLeaveBlock(t *testing.T) {
+	instr := InstrLeaveBlock{}
+	instr.isInstr()
+	expectString(t, instr.String(), "leave")
+}
+
+func TestInstrStoreAttr(t *testing.T) {
+	instr := InstrStoreAttr{"x"}
+	instr.isInstr()
+	expectString(t, instr.String(), "setattr x")
+}
+
+func TestInstrSubscript(t *testing.T) {
+	instr := InstrSubscript{}
+	instr.isInstr()
+	expectString(t, instr.String(), "index")
+}
+
 func TestInstrAdd(t *testing.T) {
 	instr := InstrAdd{}
 	instr.isInstr()
@@ -110,6 +221,60 @@ func TestInstrMul(t *testing.T) {
 	expectString(t, instr.String(), "mul")
 }
 
+func TestInstrDiv(t *testing.T) {
+	instr := InstrDiv{}
+	instr.isInstr()
+	expectString(t, instr.String(), "div")
+}
+
+func TestInstrRem(t *testing.T) {
+	instr := InstrRem{}
+	instr.isInstr()
+	expectString(t, instr.String(), "rem")
+}
+
+func TestInstrNeg(t *testing.T) {
+	instr := InstrNeg{}
+	instr.isInstr()
+	expectString(t, instr.String(), "neg")
+}
+
+func TestInstrCast(t *testing.T) {
+	instr := InstrCast{types.Optional{Child: types.BuiltinInt}}
+	instr.isInstr()
+	expectString(t, instr.String(), "cast    Int?")
+}
+
+func TestInstrIsType(t *testing.T) {
+	instr := InstrIsType{types.BuiltinStr}
+	instr.isInstr()
+	expectString(t, instr.String(), "istype  Str")
+}
+
+func TestInstrTypeof(t *testing.T) {
+	instr := InstrTypeof{}
+	instr.isInstr()
+	expectString(t, instr.String(), "typeof")
+}
+
+func TestInstrNot(t *testing.T) {
+	instr := InstrNot{}
+	instr.isInstr()
+	expectString(t, instr.String(), "not")
+}
+
+func TestInstrEquals(t *testing.T) {
+	instr := InstrEquals{}
+	instr.isInstr()
+	expectString(t, instr.String(), "cmpeq")
+}
+
+func TestInstrNotEquals(t *testing.T) {
+	instr := InstrNotEquals{}
+	instr.isInstr()
+	expectString(t, instr.String(), "cmpneq")
+}
+
 func TestInstrLT(t *testing.T) {
 	instr := InstrLT{}
 	instr.isInstr()
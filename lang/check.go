@@ -3,6 +3,7 @@ package lang
 import (
 	"fmt"
 	"plaid/lang/types"
+	"strings"
 )
 
 func Check(mod Module) (errs []error) {
@@ -32,45 +33,169 @@ func Check(mod Module) (errs []error) {
 }
 
 type binopsLUT map[string]map[types.Type]map[types.Type]types.Type
+type unopsLUT map[string]map[types.Type]types.Type
 type doubleLUT map[types.Type]map[types.Type]types.Type
 type singleLUT map[types.Type]types.Type
 
 var defaultBinopsLUT = binopsLUT{
 	"+": doubleLUT{
-		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinInt},
-		types.BuiltinStr: singleLUT{types.BuiltinStr: types.BuiltinStr},
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinInt},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinFloat},
+		types.BuiltinStr:   singleLUT{types.BuiltinStr: types.BuiltinStr},
 	},
 	"-": doubleLUT{
-		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinInt},
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinInt},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinFloat},
 	},
 	"*": doubleLUT{
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinInt},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinFloat},
+	},
+	"/": doubleLUT{
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinInt},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinFloat},
+	},
+	"%": doubleLUT{
 		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinInt},
 	},
+	"&&": doubleLUT{
+		types.BuiltinBool: singleLUT{types.BuiltinBool: types.BuiltinBool},
+	},
+	"||": doubleLUT{
+		types.BuiltinBool: singleLUT{types.BuiltinBool: types.BuiltinBool},
+	},
 	"<": doubleLUT{
-		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinBool},
 	},
 	"<=": doubleLUT{
-		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinBool},
 	},
 	">": doubleLUT{
-		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinBool},
 	},
 	">=": doubleLUT{
-		types.BuiltinInt: singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinInt:   singleLUT{types.BuiltinInt: types.BuiltinBool},
+		types.BuiltinFloat: singleLUT{types.BuiltinFloat: types.BuiltinBool},
 	},
 	"[": doubleLUT{
 		types.BuiltinStr: singleLUT{types.BuiltinInt: types.Optional{Child: types.BuiltinStr}},
 	},
 }
 
+var defaultUnopsLUT = unopsLUT{
+	"+": singleLUT{
+		types.BuiltinInt:   types.BuiltinInt,
+		types.BuiltinFloat: types.BuiltinFloat,
+	},
+	"-": singleLUT{
+		types.BuiltinInt:   types.BuiltinInt,
+		types.BuiltinFloat: types.BuiltinFloat,
+	},
+	"!": singleLUT{types.BuiltinBool: types.BuiltinBool},
+}
+
 func checkProgram(s *Scope, ast *AST) *Scope {
 	for _, stmt := range ast.Stmts {
 		checkStmt(s, stmt)
 	}
 
+	checkFlow(s, ast.Stmts)
 	return s
 }
 
+// flow describes how control leaves a statement or a list of statements
+type flow int
+
+const (
+	flowNext   flow = iota // control can continue to the next statement
+	flowJump               // control always breaks or continues a loop
+	flowReturn             // control always returns or never leaves
+)
+
+// checkFlow reports the first statement in a block that can never be reached
+// and returns how control leaves the block
+func checkFlow(s *Scope, stmts []Stmt) flow {
+	for i, stmt := range stmts {
+		if out := checkStmtFlow(s, stmt); out != flowNext {
+			if i < len(stmts)-1 {
+				addTypeError(s, stmts[i+1].Start(), "unreachable code")
+			}
+			return out
+		}
+	}
+
+	return flowNext
+}
+
+func checkStmtFlow(s *Scope, stmt Stmt) flow {
+	switch stmt := stmt.(type) {
+	case *ReturnStmt:
+		return flowReturn
+	case *BreakStmt, *ContinueStmt:
+		return flowJump
+	case *IfStmt:
+		clause := checkFlow(s, stmt.Clause.Stmts)
+
+		var alt flow
+		switch stmt := stmt.Else.(type) {
+		case *IfStmt:
+			alt = checkStmtFlow(s, stmt)
+		case *StmtBlock:
+			alt = checkFlow(s, stmt.Stmts)
+		default:
+			return flowNext
+		}
+
+		if clause == flowNext || alt == flowNext {
+			return flowNext
+		} else if clause == flowReturn && alt == flowReturn {
+			return flowReturn
+		}
+		return flowJump
+	case *WhileStmt:
+		checkFlow(s, stmt.Clause.Stmts)
+
+		// A loop that can't stop on its own has to be left with a return
+		if cond, ok := stmt.Cond.(*BooleanExpr); ok && cond.Val && containsBreak(stmt.Clause.Stmts) == false {
+			return flowReturn
+		}
+		return flowNext
+	default:
+		return flowNext
+	}
+}
+
+// containsBreak returns true if any of the statements break out of the loop
+// that immediately encloses them
+func containsBreak(stmts []Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *BreakStmt:
+			return true
+		case *IfStmt:
+			if containsBreak(stmt.Clause.Stmts) {
+				return true
+			}
+
+			switch alt := stmt.Else.(type) {
+			case *IfStmt:
+				if containsBreak([]Stmt{alt}) {
+					return true
+				}
+			case *StmtBlock:
+				if containsBreak(alt.Stmts) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
 func checkStmt(s *Scope, stmt Stmt) {
 	switch stmt := stmt.(type) {
 	case *UseStmt:
@@ -81,9 +206,22 @@ func checkStmt(s *Scope, stmt Stmt) {
 	case *IfStmt:
 		checkIfStmt(s, stmt)
 		break
+	case *WhileStmt:
+		checkWhileStmt(s, stmt)
+		break
+	case *BreakStmt:
+		break
+	case *ContinueStmt:
+		break
 	case *DeclarationStmt:
 		checkDeclarationStmt(s, stmt)
 		break
+	case *TypeStmt:
+		checkTypeStmt(s, stmt)
+		break
+	case *EnumStmt:
+		checkEnumStmt(s, stmt)
+		break
 	case *ReturnStmt:
 		checkReturnStmt(s, stmt)
 		break
@@ -99,6 +237,15 @@ func checkStmtBlock(s *Scope, block *StmtBlock) {
 	}
 }
 
+// checkNestedBlock checks a block in a new scope so that variables declared
+// inside of the block are not visible after the block
+func checkNestedBlock(s *Scope, block *StmtBlock, loop bool) {
+	local := makeBlockScope(s)
+	local.loop = loop
+	s.Children[block] = local
+	checkStmtBlock(local, block)
+}
+
 func checkUseStmt(s *Scope, stmt *UseStmt) {
 	// Do nothing.
 }
@@ -111,9 +258,23 @@ func checkPubStmt(s *Scope, stmt *PubStmt) {
 		return
 	}
 
-	name := stmt.Stmt.Name.Name
-	typ := s.Lookup(name)
-	s.Module.AddExport(name, typ)
+	switch stmt := stmt.Stmt.(type) {
+	case *DeclarationStmt:
+		name := stmt.Name.Name
+		typ := s.Lookup(name)
+		s.Module.AddExport(name, typ)
+	case *TypeStmt:
+		name := stmt.Name.Name
+		if typ := s.LookupType(name); typ != nil {
+			s.Module.AddExportedType(name, typ)
+		}
+	case *EnumStmt:
+		name := stmt.Name.Name
+		if typ := s.LookupType(name); typ != nil {
+			s.Module.AddExportedType(name, typ)
+			s.Module.AddExport(name, s.Lookup(name))
+		}
+	}
 }
 
 func checkIfStmt(s *Scope, stmt *IfStmt) {
@@ -122,27 +283,237 @@ func checkIfStmt(s *Scope, stmt *IfStmt) {
 		addTypeError(s, stmt.Cond.Start(), "condition must resolve to a boolean")
 	}
 
-	checkStmtBlock(s, stmt.Clause)
+	whenTrue := narrowings(s, stmt.Cond, true)
+	whenFalse := narrowings(s, stmt.Cond, false)
+
+	restore := s.narrow(whenTrue)
+	checkNestedBlock(s, stmt.Clause, false)
+	restore()
+
+	restore = s.narrow(whenFalse)
+	switch alt := stmt.Else.(type) {
+	case *IfStmt:
+		checkIfStmt(s, alt)
+	case *StmtBlock:
+		checkNestedBlock(s, alt, false)
+	}
+	restore()
+}
+
+func checkWhileStmt(s *Scope, stmt *WhileStmt) {
+	typ := checkExpr(s, stmt.Cond)
+	if types.BuiltinBool.Equals(typ) == false {
+		addTypeError(s, stmt.Cond.Start(), "condition must resolve to a boolean")
+	}
+
+	restore := s.narrow(narrowings(s, stmt.Cond, true))
+	checkNestedBlock(s, stmt.Clause, true)
+	restore()
+}
+
+// narrowings determines which optional variables are guaranteed to not be
+// none if the given condition evaluates to `truthy`
+func narrowings(s *Scope, cond Expr, truthy bool) map[string]types.Type {
+	narrowed := make(map[string]types.Type)
+
+	switch cond := cond.(type) {
+	case *BinaryExpr:
+		switch cond.Oper {
+		case "==", "!=":
+			if (cond.Oper == "!=") != truthy {
+				break
+			}
+
+			ident, ok := cond.Left.(*IdentExpr)
+			if _, isNone := cond.Right.(*NoneExpr); !isNone || !ok {
+				ident, ok = cond.Right.(*IdentExpr)
+				if _, isNone := cond.Left.(*NoneExpr); !isNone || !ok {
+					break
+				}
+			}
+
+			if opt, ok := s.Lookup(ident.Name).(types.Optional); ok {
+				narrowed[ident.Name] = opt.Child
+			}
+		case "&&", "||":
+			if (cond.Oper == "&&") != truthy {
+				break
+			}
+
+			for name, typ := range narrowings(s, cond.Left, truthy) {
+				narrowed[name] = typ
+			}
+
+			for name, typ := range narrowings(s, cond.Right, truthy) {
+				narrowed[name] = typ
+			}
+		}
+	case *UnaryExpr:
+		if cond.Oper == "!" {
+			return narrowings(s, cond.Expr, !truthy)
+		}
+	case *IsExpr:
+		ident, ok := cond.Expr.(*IdentExpr)
+		if target, known := s.targets[cond]; ok && known && truthy {
+			narrowed[ident.Name] = target
+		}
+	}
+
+	return narrowed
 }
 
 func checkDeclarationStmt(s *Scope, stmt *DeclarationStmt) {
 	name := stmt.Name.Name
-	typ := checkExpr(s, stmt.Expr)
-	s.AddLocal(name, typ)
+
+	var typ types.Type
+	if stmt.Note != nil {
+		typ = checkAnnotatedExpr(s, stmt.Expr, convertTypeNote(s, stmt.Note))
+	} else {
+		typ = checkExpr(s, stmt.Expr)
+	}
+
+	// Variables can shadow variables from an enclosing scope but can't be
+	// declared twice in the same scope
+	if s.HasLocal(name) && s.IsConst(name) {
+		msg := fmt.Sprintf("cannot redeclare constant '%s'", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	} else if s.HasLocal(name) {
+		msg := fmt.Sprintf("'%s' has already been declared", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	}
+
+	if stmt.IsConst() == false {
+		s.AddLocal(name, typ)
+	} else if isLiteralExpr(stmt.Expr) {
+		s.AddConst(name, typ, stmt.Expr)
+	} else {
+		s.AddConst(name, typ, nil)
+	}
+}
+
+// checkAnnotatedExpr checks an expression against a declared type. The
+// declared type is used even if the expression resolves to a narrower type
+func checkAnnotatedExpr(s *Scope, expr Expr, declared types.Type) types.Type {
+	if declared.IsError() {
+		checkExpr(s, expr)
+		return declared
+	}
+
+	typ := checkExprExpecting(s, expr, declared)
+	if typ.IsError() == false && types.AssignableTo(typ, declared) == false {
+		msg := fmt.Sprintf("'%s' cannot be assigned type '%s'", declared, typ)
+		addTypeError(s, expr.Start(), msg)
+	}
+
+	return declared
+}
+
+func isLiteralExpr(expr Expr) bool {
+	switch expr.(type) {
+	case *NumberExpr, *FloatExpr, *StringExpr, *BooleanExpr, *NoneExpr:
+		return true
+	default:
+		return false
+	}
+}
+
+// builtinTypeNames cannot be redefined by type declarations
+var builtinTypeNames = []string{"Any", "Void", "Int", "Float", "Str", "Bool"}
+
+func isBuiltinTypeName(name string) bool {
+	for _, builtin := range builtinTypeNames {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTypeStmt(s *Scope, stmt *TypeStmt) {
+	name := stmt.Name.Name
+
+	if isBuiltinTypeName(name) {
+		msg := fmt.Sprintf("cannot redeclare builtin type '%s'", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	}
+
+	if _, exists := s.Types[name]; exists {
+		msg := fmt.Sprintf("type '%s' has already been declared", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	}
+
+	s.AddType(name, convertTypeNote(s, stmt.Note))
+}
+
+// checkEnumStmt declares the enum as a type and declares a read-only struct
+// with the same name whose fields construct each of the variants
+func checkEnumStmt(s *Scope, stmt *EnumStmt) {
+	name := stmt.Name.Name
+
+	if isBuiltinTypeName(name) {
+		msg := fmt.Sprintf("cannot redeclare builtin type '%s'", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	}
+
+	if _, exists := s.Types[name]; exists {
+		msg := fmt.Sprintf("type '%s' has already been declared", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	}
+
+	if s.HasLocal(name) {
+		msg := fmt.Sprintf("'%s' has already been declared", name)
+		addTypeError(s, stmt.Name.Start(), msg)
+		return
+	}
+
+	union := types.Union{Name: name}
+	for _, variant := range stmt.Variants {
+		payload := []types.Type{}
+		for _, note := range variant.Payload {
+			payload = append(payload, convertTypeNote(s, note))
+		}
+
+		union.Variants = append(union.Variants, types.Variant{
+			Name:    variant.Name.Name,
+			Payload: payload,
+		})
+	}
+
+	constructors := types.Struct{}
+	for _, variant := range union.Variants {
+		var typ types.Type = union
+		if len(variant.Payload) > 0 {
+			typ = types.Function{Params: types.Tuple{Children: variant.Payload}, Ret: union}
+		}
+
+		constructors.Fields = append(constructors.Fields, struct {
+			Name string
+			Type types.Type
+		}{variant.Name, typ})
+	}
+
+	s.AddType(name, union)
+	s.AddConst(name, constructors, nil)
 }
 
 func checkReturnStmt(s *Scope, stmt *ReturnStmt) {
 	var ret types.Type = types.Void{}
 	if stmt.Expr != nil {
-		ret = checkExpr(s, stmt.Expr)
+		ret = checkExprExpecting(s, stmt.Expr, s.Self.Ret)
 	}
 
-	if s.Parent == nil {
+	if s.inFunction() == false {
 		addTypeError(s, stmt.Start(), "return statements must be inside a function")
 		return
 	}
 
-	if s.Self.Ret.Equals(ret) || ret.IsError() {
+	if types.AssignableTo(ret, s.Self.Ret) || ret.IsError() {
 		return
 	}
 
@@ -171,10 +542,24 @@ func checkExprAllowVoid(s *Scope, expr Expr) types.Type {
 		typ = checkDispatchExpr(s, expr)
 	case *AssignExpr:
 		typ = checkAssignExpr(s, expr)
+	case *FieldAssignExpr:
+		typ = checkFieldAssignExpr(s, expr)
 	case *BinaryExpr:
 		typ = checkBinaryExpr(s, expr, defaultBinopsLUT)
+	case *UnaryExpr:
+		typ = checkUnaryExpr(s, expr, defaultUnopsLUT)
+	case *CastExpr:
+		typ = checkCastExpr(s, expr)
+	case *IsExpr:
+		typ = checkIsExpr(s, expr)
+	case *TypeofExpr:
+		typ = checkTypeofExpr(s, expr)
 	case *ListExpr:
-		typ = checkListExpr(s, expr)
+		typ = checkListExpr(s, expr, nil)
+	case *MatchExpr:
+		typ = checkMatchExpr(s, expr)
+	case *StructExpr:
+		typ = checkStructExpr(s, expr)
 	case *SubscriptExpr:
 		typ = checkSubscriptExpr(s, expr, defaultBinopsLUT)
 	case *AccessExpr:
@@ -185,10 +570,14 @@ func checkExprAllowVoid(s *Scope, expr Expr) types.Type {
 		typ = checkIdentExpr(s, expr)
 	case *NumberExpr:
 		typ = checkNumberExpr(s, expr)
+	case *FloatExpr:
+		typ = checkFloatExpr(s, expr)
 	case *StringExpr:
 		typ = checkStringExpr(s, expr)
 	case *BooleanExpr:
 		typ = checkBooleanExpr(s, expr)
+	case *NoneExpr:
+		typ = checkNoneExpr(s, expr)
 	default:
 		addTypeError(s, expr.Start(), "unknown expression type")
 	}
@@ -196,6 +585,17 @@ func checkExprAllowVoid(s *Scope, expr Expr) types.Type {
 	return typ
 }
 
+// checkExprExpecting checks an expression that's expected to have the given
+// type. List literals take their element type from the expected type so that
+// empty lists nested at any depth can be typed
+func checkExprExpecting(s *Scope, expr Expr, expected types.Type) types.Type {
+	if list, ok := expr.(*ListExpr); ok {
+		return checkListExpr(s, list, expected)
+	}
+
+	return checkExpr(s, expr)
+}
+
 func checkExpr(s *Scope, expr Expr) types.Type {
 	typ := checkExprAllowVoid(s, expr)
 
@@ -208,38 +608,68 @@ func checkExpr(s *Scope, expr Expr) types.Type {
 }
 
 func checkFunctionExpr(s *Scope, expr *FunctionExpr) types.Type {
-	ret := convertTypeNote(expr.Ret)
+	childScope := makeScope(s)
+	s.Children[expr] = childScope
+
+	// Type parameters are only visible inside of the function
+	var vars []types.Var
+	for _, param := range expr.TypeParams {
+		if isBuiltinTypeName(param.Name) {
+			msg := fmt.Sprintf("cannot use builtin type '%s' as a type parameter", param.Name)
+			addTypeError(s, param.Start(), msg)
+			continue
+		}
+
+		v := types.Var{Name: param.Name}
+		vars = append(vars, v)
+		childScope.AddType(param.Name, v)
+	}
+
+	ret := convertTypeNote(childScope, expr.Ret)
 	params := []types.Type{}
 	for _, param := range expr.Params {
-		params = append(params, convertTypeNote(param.Note))
+		params = append(params, convertTypeNote(childScope, param.Note))
 	}
 	tuple := types.Tuple{Children: params}
-	self := types.Function{Params: tuple, Ret: ret}
-
-	childScope := makeScope(s)
-	s.Children[expr] = childScope
+	self := types.Function{Params: tuple, Ret: ret, TypeParams: vars}
 	childScope.Self = self
 
-	for _, param := range expr.Params {
-		paramName := param.Name.Name
-		paramType := convertTypeNote(param.Note)
-		childScope.AddLocal(paramName, paramType)
+	for i, param := range expr.Params {
+		if childScope.HasLocal(param.Name.Name) {
+			msg := fmt.Sprintf("'%s' has already been declared", param.Name.Name)
+			addTypeError(s, param.Name.Start(), msg)
+			continue
+		}
+		childScope.AddLocal(param.Name.Name, params[i])
+		childScope.params = append(childScope.params, param.Name.Name)
 	}
 
 	checkStmtBlock(childScope, expr.Block)
+
+	out := checkFlow(childScope, expr.Block.Stmts)
+	if out != flowReturn && ret != nil && ret.IsError() == false && (types.Void{}).Equals(ret) == false {
+		msg := fmt.Sprintf("missing return of type '%s' at end of function", ret)
+		addTypeError(childScope, expr.Block.Right.Loc, msg)
+	}
 	return self
 }
 
 func checkDispatchExpr(s *Scope, expr *DispatchExpr) types.Type {
-	// Resolve arguments to types
-	argTypes := []types.Type{}
-	for _, argExpr := range expr.Args {
-		argTypes = append(argTypes, checkExpr(s, argExpr))
-	}
-
 	// Resolve callee to type
 	calleeType := checkExpr(s, expr.Callee)
 	calleeFunc, ok := calleeType.(types.Function)
+
+	// Resolve arguments to types. The parameter types of a function that
+	// isn't generic are what the arguments are expected to be
+	argTypes := []types.Type{}
+	for i, argExpr := range expr.Args {
+		var expected types.Type
+		if ok && len(calleeFunc.TypeParams) == 0 && i < len(calleeFunc.Params.Children) {
+			expected = calleeFunc.Params.Children[i]
+		}
+		argTypes = append(argTypes, checkExprExpecting(s, argExpr, expected))
+	}
+
 	if ok == false {
 		if calleeType.IsError() == false {
 			msg := fmt.Sprintf("cannot call function on type '%s'", calleeType)
@@ -249,6 +679,13 @@ func checkDispatchExpr(s *Scope, expr *DispatchExpr) types.Type {
 		return types.Error{}
 	}
 
+	// Generic functions are specialized to the types of the arguments
+	if len(calleeFunc.TypeParams) > 0 {
+		if calleeFunc, ok = instantiateFunction(s, expr, calleeFunc, argTypes); ok == false {
+			return types.Error{}
+		}
+	}
+
 	// Resolve return type
 	retType := calleeFunc.Ret
 
@@ -262,7 +699,7 @@ func checkDispatchExpr(s *Scope, expr *DispatchExpr) types.Type {
 
 			if argType.IsError() {
 				retType = types.Error{}
-			} else if paramType.Equals(argType) == false {
+			} else if types.AssignableTo(argType, paramType) == false {
 				msg := fmt.Sprintf("expected '%s', got '%s'", paramType, argType)
 				addTypeError(s, expr.Args[i].Start(), msg)
 				retType = types.Error{}
@@ -277,9 +714,34 @@ func checkDispatchExpr(s *Scope, expr *DispatchExpr) types.Type {
 	return retType
 }
 
+// instantiateFunction infers the type variables of a generic function from
+// the types of the arguments passed to it and returns the function signature
+// with each type variable replaced by its inferred type
+func instantiateFunction(s *Scope, expr *DispatchExpr, fn types.Function, argTypes []types.Type) (types.Function, bool) {
+	bindings := types.Bindings{}
+	for i, argType := range argTypes {
+		if i < len(fn.Params.Children) {
+			types.Unify(fn.Params.Children[i], argType, fn.TypeParams, bindings)
+		}
+	}
+
+	for _, v := range fn.TypeParams {
+		if _, ok := bindings[v.Name]; ok == false {
+			msg := fmt.Sprintf("cannot infer type parameter '%s'", v.Name)
+			addTypeError(s, expr.Start(), msg)
+			return fn, false
+		}
+	}
+
+	return types.Function{
+		Params: types.Substitute(fn.Params, bindings).(types.Tuple),
+		Ret:    types.Substitute(fn.Ret, bindings),
+	}, true
+}
+
 func checkAssignExpr(s *Scope, expr *AssignExpr) types.Type {
 	name := expr.Left.Name
-	leftType := s.Lookup(name)
+	leftType := s.LookupDeclared(name)
 	rightType := checkExpr(s, expr.Right)
 
 	if leftType == nil {
@@ -288,11 +750,150 @@ func checkAssignExpr(s *Scope, expr *AssignExpr) types.Type {
 		return types.Error{}
 	}
 
+	if s.IsConst(name) {
+		msg := fmt.Sprintf("cannot assign to constant '%s'", name)
+		addTypeError(s, expr.Start(), msg)
+		return types.Error{}
+	}
+
 	if leftType.IsError() || rightType.IsError() {
 		return types.Error{}
 	}
 
-	if leftType.Equals(rightType) == false {
+	if types.AssignableTo(rightType, leftType) == false {
+		msg := fmt.Sprintf("'%s' cannot be assigned type '%s'", leftType, rightType)
+		addTypeError(s, expr.Right.Start(), msg)
+		return types.Error{}
+	}
+
+	s.bind(expr.Left)
+
+	// An assignment can invalidate a narrowed type
+	if types.AssignableTo(rightType, s.Lookup(name)) == false {
+		s.widen(name)
+	}
+
+	return leftType
+}
+
+// isEnumConstructors returns true if the name refers to the struct of variant
+// constructors declared by an enum
+func isEnumConstructors(s *Scope, name string) bool {
+	_, isUnion := s.LookupType(name).(types.Union)
+	return isUnion && s.IsConst(name)
+}
+
+func checkMatchExpr(s *Scope, expr *MatchExpr) types.Type {
+	subjectType := checkExpr(s, expr.Subject)
+	union, ok := subjectType.(types.Union)
+	if ok == false && subjectType.IsError() == false {
+		msg := fmt.Sprintf("cannot match on type '%s'", subjectType)
+		addTypeError(s, expr.Subject.Start(), msg)
+	}
+
+	var result types.Type
+	covered := make(map[string]bool)
+	wildcard := false
+	for _, arm := range expr.Arms {
+		armScope := makeBlockScope(s)
+		s.Children[arm] = armScope
+
+		if wildcard {
+			addTypeError(s, arm.Start(), "unreachable match arm")
+		} else if arm.IsWildcard() {
+			wildcard = true
+		} else if ok {
+			checkMatchArmPattern(s, armScope, union, arm, covered)
+		}
+
+		typ := checkExpr(armScope, arm.Expr)
+		if typ.IsError() {
+			continue
+		}
+
+		if result == nil || types.AssignableTo(result, typ) {
+			result = typ
+		} else if types.AssignableTo(typ, result) == false {
+			msg := fmt.Sprintf("expected '%s', got '%s'", result, typ)
+			addTypeError(s, arm.Expr.Start(), msg)
+		}
+	}
+
+	if ok == false {
+		return types.Error{}
+	}
+
+	if wildcard == false {
+		var missing []string
+		for _, variant := range union.Variants {
+			if covered[variant.Name] == false {
+				missing = append(missing, fmt.Sprintf("'%s'", variant.Name))
+			}
+		}
+
+		if len(missing) > 0 {
+			msg := fmt.Sprintf("match is not exhaustive, missing %s", strings.Join(missing, ", "))
+			addTypeError(s, expr.Start(), msg)
+			return types.Error{}
+		}
+	}
+
+	if result == nil {
+		return types.Error{}
+	}
+
+	return result
+}
+
+// checkMatchArmPattern checks that an arm names a variant of the union that
+// no earlier arm has covered and binds the values carried by that variant in
+// the arm's scope
+func checkMatchArmPattern(s *Scope, armScope *Scope, union types.Union, arm *MatchArm, covered map[string]bool) {
+	name := arm.Variant.Name
+	variant, exists := union.Variant(name)
+	if exists == false {
+		msg := fmt.Sprintf("'%s' has no variant '%s'", union.Name, name)
+		addTypeError(s, arm.Variant.Start(), msg)
+		return
+	}
+
+	if covered[name] {
+		msg := fmt.Sprintf("variant '%s' is already matched", name)
+		addTypeError(s, arm.Variant.Start(), msg)
+	}
+	covered[name] = true
+
+	if len(arm.Bindings) > 0 && len(arm.Bindings) != len(variant.Payload) {
+		msg := fmt.Sprintf("variant '%s' carries %d values, got %d bindings",
+			name, len(variant.Payload), len(arm.Bindings))
+		addTypeError(s, arm.Variant.Start(), msg)
+		return
+	}
+
+	for i, binding := range arm.Bindings {
+		armScope.AddLocal(binding.Name, variant.Payload[i])
+	}
+}
+
+func checkFieldAssignExpr(s *Scope, expr *FieldAssignExpr) types.Type {
+	if root, ok := expr.Left.Left.(*IdentExpr); ok && s.IsModule(root.Name) {
+		msg := fmt.Sprintf("cannot assign to members of module '%s'", root.Name)
+		addTypeError(s, expr.Start(), msg)
+		return types.Error{}
+	} else if ok && isEnumConstructors(s, root.Name) {
+		msg := fmt.Sprintf("cannot assign to variants of enum '%s'", root.Name)
+		addTypeError(s, expr.Start(), msg)
+		return types.Error{}
+	}
+
+	leftType := checkAccessExpr(s, expr.Left)
+	rightType := checkExpr(s, expr.Right)
+
+	if leftType.IsError() || rightType.IsError() {
+		return types.Error{}
+	}
+
+	if types.AssignableTo(rightType, leftType) == false {
 		msg := fmt.Sprintf("'%s' cannot be assigned type '%s'", leftType, rightType)
 		addTypeError(s, expr.Right.Start(), msg)
 		return types.Error{}
@@ -303,12 +904,30 @@ func checkAssignExpr(s *Scope, expr *AssignExpr) types.Type {
 
 func checkBinaryExpr(s *Scope, expr *BinaryExpr, lut binopsLUT) types.Type {
 	leftType := checkExpr(s, expr.Left)
-	rightType := checkExpr(s, expr.Right)
+
+	// The right side of a logical operator is only evaluated after the left
+	// side has been tested so that test can narrow types on the right side
+	var rightType types.Type
+	switch expr.Oper {
+	case "&&", "||":
+		restore := s.narrow(narrowings(s, expr.Left, expr.Oper == "&&"))
+		rightType = checkExpr(s, expr.Right)
+		restore()
+	default:
+		rightType = checkExpr(s, expr.Right)
+	}
 
 	if leftType.IsError() || rightType.IsError() {
 		return types.Error{}
 	}
 
+	switch expr.Oper {
+	case "==", "!=":
+		return checkEqualityOperands(s, expr, leftType, rightType)
+	case "??":
+		return checkCoalesceOperands(s, expr, leftType, rightType)
+	}
+
 	if operLUT, ok := lut[expr.Oper]; ok {
 		if leftLUT, ok := operLUT[leftType]; ok {
 			if retType, ok := leftLUT[rightType]; ok {
@@ -326,10 +945,191 @@ func checkBinaryExpr(s *Scope, expr *BinaryExpr, lut binopsLUT) types.Type {
 	return types.Error{}
 }
 
-func checkListExpr(s *Scope, expr *ListExpr) types.Type {
+// checkCastExpr resolves `a as T` to T? since the cast produces none when
+// the value doesn't have the type T at runtime
+func checkCastExpr(s *Scope, expr *CastExpr) types.Type {
+	target := checkTypeTarget(s, expr, expr.Expr, expr.Note)
+	if target.IsError() {
+		return types.Error{}
+	} else if opt, ok := target.(types.Optional); ok {
+		return opt
+	}
+
+	return types.Optional{Child: target}
+}
+
+func checkIsExpr(s *Scope, expr *IsExpr) types.Type {
+	if checkTypeTarget(s, expr, expr.Expr, expr.Note).IsError() {
+		return types.Error{}
+	}
+
+	return types.BuiltinBool
+}
+
+// checkTypeTarget resolves the type that a cast or type test compares a
+// value against. The resolved type is recorded in the scope for narrowing and
+// for the compiler
+func checkTypeTarget(s *Scope, node Expr, expr Expr, note TypeNote) types.Type {
+	typ := checkExpr(s, expr)
+	target := convertTypeNote(s, note)
+	s.targets[node] = target
+
+	if typ.IsError() || target.IsError() {
+		return types.Error{}
+	}
+
+	if isRuntimeType(target) == false {
+		msg := fmt.Sprintf("cannot check for type '%s' at runtime", target)
+		addTypeError(s, note.Start(), msg)
+		return types.Error{}
+	}
+
+	if types.AssignableTo(target, typ) == false && types.AssignableTo(typ, target) == false {
+		msg := fmt.Sprintf("type '%s' can never be '%s'", typ, target)
+		addTypeError(s, expr.Start(), msg)
+		return types.Error{}
+	}
+
+	return target
+}
+
+// isRuntimeType returns true if the VM can tell whether a value has the
+// type. Functions don't carry their signature at runtime so function types
+// can't be tested
+func isRuntimeType(typ types.Type) bool {
+	switch typ := typ.(type) {
+	case types.Optional:
+		return isRuntimeType(typ.Child)
+	case types.List:
+		return isRuntimeType(typ.Child)
+	case types.Struct:
+		for _, field := range typ.Fields {
+			if isRuntimeType(field.Type) == false {
+				return false
+			}
+		}
+		return true
+	case types.Union:
+		for _, variant := range typ.Variants {
+			for _, payload := range variant.Payload {
+				if isRuntimeType(payload) == false {
+					return false
+				}
+			}
+		}
+		return true
+	case types.Any, types.None, types.Ident:
+		return true
+	default:
+		return false
+	}
+}
+
+func checkTypeofExpr(s *Scope, expr *TypeofExpr) types.Type {
+	if checkExpr(s, expr.Expr).IsError() {
+		return types.Error{}
+	}
+
+	return types.BuiltinStr
+}
+
+func checkUnaryExpr(s *Scope, expr *UnaryExpr, lut unopsLUT) types.Type {
+	operandType := checkExpr(s, expr.Expr)
+
+	if operandType.IsError() {
+		return types.Error{}
+	}
+
+	if operLUT, ok := lut[expr.Oper]; ok {
+		if retType, ok := operLUT[operandType]; ok {
+			return retType
+		}
+
+		msg := fmt.Sprintf("operator '%s' does not support %s", expr.Oper, operandType)
+		addTypeError(s, expr.Tok.Loc, msg)
+		return types.Error{}
+	}
+
+	msg := fmt.Sprintf("unknown prefix operator '%s'", expr.Oper)
+	addTypeError(s, expr.Tok.Loc, msg)
+	return types.Error{}
+}
+
+// checkEqualityOperands allows any two operands to be compared for equality
+// so long as both operands have the same type
+func checkEqualityOperands(s *Scope, expr *BinaryExpr, leftType types.Type, rightType types.Type) types.Type {
+	if leftType.Equals(rightType) && rightType.Equals(leftType) {
+		return types.BuiltinBool
+	}
+
+	// Optional values can be compared against none or against a value of the
+	// optional's child type
+	if opt, ok := leftType.(types.Optional); ok && comparableWithOptional(opt, rightType) {
+		return types.BuiltinBool
+	} else if opt, ok := rightType.(types.Optional); ok && comparableWithOptional(opt, leftType) {
+		return types.BuiltinBool
+	}
+
+	msg := fmt.Sprintf("operator '%s' does not support %s and %s", expr.Oper, leftType, rightType)
+	addTypeError(s, expr.Tok.Loc, msg)
+	return types.Error{}
+}
+
+func comparableWithOptional(opt types.Optional, other types.Type) bool {
+	if _, ok := other.(types.None); ok {
+		return true
+	}
+
+	return opt.Child.Equals(other) && other.Equals(opt.Child)
+}
+
+// checkCoalesceOperands resolves `a ?? b` to the child type of `a` if `b`
+// can stand in for a missing value
+func checkCoalesceOperands(s *Scope, expr *BinaryExpr, leftType types.Type, rightType types.Type) types.Type {
+	if opt, ok := leftType.(types.Optional); ok {
+		if types.AssignableTo(rightType, opt.Child) {
+			return opt.Child
+		} else if types.AssignableTo(rightType, opt) {
+			return opt
+		}
+	}
+
+	msg := fmt.Sprintf("operator '%s' does not support %s and %s", expr.Oper, leftType, rightType)
+	addTypeError(s, expr.Tok.Loc, msg)
+	return types.Error{}
+}
+
+// checkListExpr determines the type of a list literal. If the list is expected
+// to have a list type, optional or not, every element has to be assignable to
+// the expected element type and the list gets that element type. Otherwise
+// the element type is inferred from the elements
+func checkListExpr(s *Scope, expr *ListExpr, expected types.Type) types.Type {
+	if opt, ok := expected.(types.Optional); ok {
+		expected = opt.Child
+	}
+
+	var elemExpected types.Type
+	if list, ok := expected.(types.List); ok {
+		elemExpected = list.Child
+	}
+
 	var elemTypes []types.Type
 	for _, elem := range expr.Elements {
-		elemTypes = append(elemTypes, checkExpr(s, elem))
+		elemTypes = append(elemTypes, checkExprExpecting(s, elem, elemExpected))
+	}
+
+	if elemExpected != nil {
+		for i, typ := range elemTypes {
+			if typ.IsError() {
+				return types.Error{}
+			} else if types.AssignableTo(typ, elemExpected) == false {
+				msg := fmt.Sprintf("element type %s is not compatible with type %s", typ, elemExpected)
+				addTypeError(s, expr.Elements[i].Start(), msg)
+				return types.Error{}
+			}
+		}
+
+		return types.List{Child: elemExpected}
 	}
 
 	if len(elemTypes) == 0 {
@@ -351,7 +1151,13 @@ func checkListExpr(s *Scope, expr *ListExpr) types.Type {
 			continue
 		}
 
-		if listType.Equals(typ) == false {
+		// The list type widens to fit every element so `[1, a]` with `a` of
+		// type Int? is an [Int?]
+		if types.AssignableTo(typ, listType) {
+			continue
+		} else if types.AssignableTo(listType, typ) {
+			listType = typ
+		} else {
 			msg := fmt.Sprintf("element type %s is not compatible with type %s", typ, listType)
 			addTypeError(s, expr.Elements[i].Start(), msg)
 			return types.Error{}
@@ -361,6 +1167,25 @@ func checkListExpr(s *Scope, expr *ListExpr) types.Type {
 	return types.List{Child: listType}
 }
 
+func checkStructExpr(s *Scope, expr *StructExpr) types.Type {
+	hasErrors := false
+	structType := types.Struct{}
+	for _, field := range expr.Fields {
+		typ := checkExpr(s, field.Expr)
+		hasErrors = hasErrors || typ.IsError()
+		structType.Fields = append(structType.Fields, struct {
+			Name string
+			Type types.Type
+		}{field.Name.Name, typ})
+	}
+
+	if hasErrors {
+		return types.Error{}
+	}
+
+	return structType
+}
+
 func checkSubscriptExpr(s *Scope, expr *SubscriptExpr, lut binopsLUT) types.Type {
 	listType := checkExpr(s, expr.ListLike)
 	indexType := checkExpr(s, expr.Index)
@@ -370,6 +1195,12 @@ func checkSubscriptExpr(s *Scope, expr *SubscriptExpr, lut binopsLUT) types.Type
 	}
 
 	if listType, ok := listType.(types.List); ok {
+		if indexType.Equals(types.BuiltinInt) == false {
+			msg := fmt.Sprintf("subscript operator does not support %s[%s]", listType, indexType)
+			addTypeError(s, expr.Index.Start(), msg)
+			return types.Error{}
+		}
+
 		return types.Optional{Child: listType.Child}
 	}
 
@@ -409,7 +1240,7 @@ func checkAccessExpr(s *Scope, expr *AccessExpr) types.Type {
 }
 
 func checkSelfExpr(s *Scope, expr *SelfExpr) types.Type {
-	if s.Parent == nil {
+	if s.inFunction() == false {
 		addTypeError(s, expr.Start(), "self references must be inside a function")
 		return types.Error{}
 	}
@@ -419,6 +1250,7 @@ func checkSelfExpr(s *Scope, expr *SelfExpr) types.Type {
 
 func checkIdentExpr(s *Scope, expr *IdentExpr) types.Type {
 	if typ := s.Lookup(expr.Name); typ != nil {
+		s.bind(expr)
 		return typ
 	}
 
@@ -431,6 +1263,14 @@ func checkNumberExpr(s *Scope, expr *NumberExpr) types.Type {
 	return types.BuiltinInt
 }
 
+func checkFloatExpr(s *Scope, expr *FloatExpr) types.Type {
+	return types.BuiltinFloat
+}
+
+func checkNoneExpr(s *Scope, expr *NoneExpr) types.Type {
+	return types.None{}
+}
+
 func checkStringExpr(s *Scope, expr *StringExpr) types.Type {
 	return types.BuiltinStr
 }
@@ -450,13 +1290,95 @@ func addTypeError(s *Scope, loc Loc, msg string) {
 	s.Errors = append(s.Errors, err)
 }
 
+// addUnknownTypeError reports a type name that could not be resolved and, if
+// one of the known names is a close enough match, suggests it as a fix
+func addUnknownTypeError(s *Scope, loc Loc, name string, known []string) {
+	msg := fmt.Sprintf("unknown type '%s'", name)
+	if suggestion := closestName(name, known); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean '%s'?", suggestion)
+	}
+	addTypeError(s, loc, msg)
+}
+
+func qualifyNames(module string, names []string) (qualified []string) {
+	for _, name := range names {
+		qualified = append(qualified, module+"."+name)
+	}
+	return qualified
+}
+
+// closestName returns the candidate with the smallest edit distance from the
+// given name. Candidates that differ from the name by more than a third of
+// its length are not considered similar and if no candidate is similar the
+// empty string is returned. Ties are broken alphabetically
+func closestName(name string, candidates []string) string {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	best := ""
+	bestDist := limit + 1
+	for _, candidate := range candidates {
+		dist := editDistance(name, candidate)
+		if dist < bestDist || (dist == bestDist && candidate < best) {
+			best = candidate
+			bestDist = dist
+		}
+	}
+
+	return best
+}
+
+// editDistance computes the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters needed to turn one string into
+// the other
+func editDistance(a string, b string) int {
+	r1, r2 := []rune(a), []rune(b)
+	dist := make([][]int, len(r1)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(r2)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+
+	for i := 1; i <= len(r1); i++ {
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+
+			dist[i][j] = minInt(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+			if i > 1 && j > 1 && r1[i-1] == r2[j-2] && r1[i-2] == r2[j-1] {
+				dist[i][j] = minInt(dist[i][j], dist[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return dist[len(r1)][len(r2)]
+}
+
+func minInt(first int, rest ...int) int {
+	min := first
+	for _, n := range rest {
+		if n < min {
+			min = n
+		}
+	}
+	return min
+}
+
 func (err TypeCheckError) Error() string {
 	return fmt.Sprintf("%s %s", err.Loc, err.Message)
 }
 
 // convertTypeNote transforms a TypeNote struct (used to represent a syntax
-// type notation) into a Type struct (used internally to represent a type)
-func convertTypeNote(note TypeNote) types.Type {
+// type notation) into a Type struct (used internally to represent a type).
+// Names bound by type declarations are replaced by the types they refer to
+func convertTypeNote(s *Scope, note TypeNote) types.Type {
 	switch note := note.(type) {
 	case TypeNoteAny:
 		return types.Any{}
@@ -464,21 +1386,61 @@ func convertTypeNote(note TypeNote) types.Type {
 		return types.Void{}
 	case TypeNoteFunction:
 		return types.Function{
-			Params: convertTypeNote(note.Params).(types.Tuple),
-			Ret:    convertTypeNote(note.Ret),
+			Params: convertTypeNote(s, note.Params).(types.Tuple),
+			Ret:    convertTypeNote(s, note.Ret),
 		}
 	case TypeNoteTuple:
 		elems := []types.Type{}
 		for _, elem := range note.Elems {
-			elems = append(elems, convertTypeNote(elem))
+			elems = append(elems, convertTypeNote(s, elem))
 		}
 		return types.Tuple{Children: elems}
 	case TypeNoteList:
-		return types.List{Child: convertTypeNote(note.Child)}
+		return types.List{Child: convertTypeNote(s, note.Child)}
+	case TypeNoteStruct:
+		structType := types.Struct{}
+		for _, field := range note.Fields {
+			structType.Fields = append(structType.Fields, struct {
+				Name string
+				Type types.Type
+			}{field.Name.Name, convertTypeNote(s, field.Note)})
+		}
+		return structType
 	case TypeNoteOptional:
-		return types.Optional{Child: convertTypeNote(note.Child)}
+		return types.Optional{Child: convertTypeNote(s, note.Child)}
 	case TypeNoteIdent:
+		if typ := s.LookupType(note.Name); typ != nil {
+			return typ
+		}
+
+		known := append(s.TypeNames(), builtinTypeNames...)
+		for _, name := range known {
+			if name == note.Name {
+				return types.Ident{Name: note.Name}
+			}
+		}
+
+		addUnknownTypeError(s, note.Start(), note.Name, known)
 		return types.Ident{Name: note.Name}
+	case TypeNoteQualified:
+		exported, ok := s.LookupModuleTypes(note.Module)
+		if ok == false {
+			msg := fmt.Sprintf("unknown module '%s'", note.Module)
+			addTypeError(s, note.Start(), msg)
+			return types.Ident{Name: note.String()}
+		}
+
+		if typ, ok := exported[note.Name]; ok {
+			return typ
+		}
+
+		var known []string
+		for name := range exported {
+			known = append(known, name)
+		}
+
+		addUnknownTypeError(s, note.Start(), note.String(), qualifyNames(note.Module, known))
+		return types.Ident{Name: note.String()}
 	default:
 		return nil
 	}
@@ -49,9 +49,198 @@ func TestCheckPubStmt(t *testing.T) {
 	bad("pub let x := 100;", "(1:1) pub statement must be a top-level statement")
 }
 
+func TestCheckTypeStmt(t *testing.T) {
+	goodProgram(t, "type Id := Int; let f := fn (n: Id): Int { return n; };")
+	goodProgram(t, `
+		type Point := {x: Int y: Int};
+		type Mover := (Point) => Point;
+		let shift := fn (p: Point): Point { return {x: p.x + 1, y: p.y}; };
+		let apply := fn (m: Mover, p: Point): Int { return m(p).x; };
+		apply(shift, {x: 1, y: 2});`)
+	badProgram(t, "type Id := Int; type Id := Str;", "(1:22) type 'Id' has already been declared")
+	badProgram(t, "type Int := Str;", "(1:6) cannot redeclare builtin type 'Int'")
+	badProgram(t, "type Id := Int; let f := fn (n: Id): Str { return n; };",
+		"(1:51) expected to return 'Str', got 'Int'")
+
+	prog, _ := ParseString("type Pair := {a: Int b: Str}; pub type Id := Int;")
+	s := makeScope(nil)
+	mod := &ModuleVirtual{}
+	s.Module = mod
+	checkProgram(s, prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.LookupType("Pair"), types.Struct{Fields: []struct {
+		Name string
+		Type types.Type
+	}{
+		{"a", types.BuiltinInt},
+		{"b", types.BuiltinStr},
+	}})
+	expectEquivalentType(t, mod.ExportedTypes()["Id"], types.BuiltinInt)
+	expectBool(t, mod.ExportedTypes()["Pair"] == nil, true)
+
+	// Types exported by a dependency are available as qualified names
+	user := &ModuleVirtual{}
+	user.link("lib", "./lib.plaid", mod)
+	prog, _ = ParseString("let f := fn (n: lib.Id): Int { return n; };")
+	s = makeScope(nil)
+	s.Module = user
+	checkProgram(s, prog)
+	expectNoXScopeErrors(t, s)
+}
+
+func TestCheckUnknownTypes(t *testing.T) {
+	goodProgram(t, "let f := fn (a: Int, b: Float, c: Str, d: Bool): Any { return a; };")
+	badProgram(t, "let f := fn (n: Itn): Void {};", "(1:17) unknown type 'Itn', did you mean 'Int'?")
+	badProgram(t, "let f := fn (): [Strr] { return []; };", "(1:18) unknown type 'Strr', did you mean 'Str'?")
+	badProgram(t, "let f := fn (p: Widget): Void {};", "(1:17) unknown type 'Widget'")
+	badProgram(t, "type Point := {x: Int}; let f := fn (p: Pointt?): Void {};",
+		"(1:41) unknown type 'Pointt', did you mean 'Point'?")
+	badProgram(t, "type A := B;", "(1:11) unknown type 'B'")
+	badProgram(t, "let f := fn (p: geo.Point): Void {};", "(1:17) unknown module 'geo'")
+
+	lib := &ModuleVirtual{}
+	lib.AddExportedType("Point", types.Struct{})
+	user := &ModuleVirtual{}
+	user.link("geo", "./geo.plaid", lib)
+	prog, _ := ParseString("let f := fn (p: geo.Pont): Void {};")
+	s := makeScope(nil)
+	s.Module = user
+	checkProgram(s, prog)
+	expectNthXScopeError(t, s, 0, "(1:17) unknown type 'geo.Pont', did you mean 'geo.Point'?")
+}
+
+func TestClosestName(t *testing.T) {
+	known := []string{"Int", "Float", "Str", "Bool", "Point", "Pair"}
+	expectString(t, closestName("Itn", known), "Int")
+	expectString(t, closestName("int", known), "Int")
+	expectString(t, closestName("Flaot", known), "Float")
+	expectString(t, closestName("Pain", known), "Pair")
+	expectString(t, closestName("Widget", known), "")
+	expectString(t, closestName("X", nil), "")
+}
+
+func TestCheckEnumStmt(t *testing.T) {
+	prog, _ := ParseString("enum Shape { Circle(Float) Empty }; let a := Shape.Circle(1.0); let b := Shape.Empty;")
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	shape := types.Union{Name: "Shape", Variants: []types.Variant{
+		{Name: "Circle", Payload: []types.Type{types.BuiltinFloat}},
+		{Name: "Empty", Payload: []types.Type{}},
+	}}
+	expectEquivalentType(t, s.LookupType("Shape"), shape)
+	expectEquivalentType(t, s.Lookup("a"), shape)
+	expectEquivalentType(t, s.Lookup("b"), shape)
+
+	goodProgram(t, "enum Tree { Leaf Node(Int, [Int]) }; let f := fn (t: Tree): Tree { return t; };")
+	badProgram(t, "enum Int { A };", "(1:6) cannot redeclare builtin type 'Int'")
+	badProgram(t, "enum E { A }; enum E { B };", "(1:20) type 'E' has already been declared")
+	badProgram(t, "let E := 1; enum E { A };", "(1:18) 'E' has already been declared")
+	badProgram(t, "enum E { A(Itn) };", "(1:12) unknown type 'Itn', did you mean 'Int'?")
+	badProgram(t, "enum E { A(Int) }; E.A(true);", "(1:24) expected 'Int', got 'Bool'")
+	badProgram(t, "enum E { A }; E.A := E.A;", "(1:15) cannot assign to variants of enum 'E'")
+	badProgram(t, "enum E { A }; E := E;", "(1:15) cannot assign to constant 'E'")
+
+	prog, _ = ParseString("pub enum E { A };")
+	s = makeScope(nil)
+	mod := &ModuleVirtual{}
+	s.Module = mod
+	checkProgram(s, prog)
+	expectNoXScopeErrors(t, s)
+	expectString(t, mod.ExportedTypes()["E"].String(), "E")
+	expectString(t, mod.Exports().Member("E").String(), "{A:E}")
+}
+
+func TestCheckMatchExpr(t *testing.T) {
+	const shape = "enum Shape { Circle(Float) Rect(Float, Float) Empty };"
+	prog, _ := ParseString(shape + `
+		let area := fn (s: Shape): Float {
+			return match s {
+				Circle(r) => 3.0 * r * r,
+				Rect(w, h) => w * h,
+				Empty => 0.0,
+			};
+		};
+		let name := match Shape.Empty { Circle => "circle" else => "other" };`)
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("name"), types.BuiltinStr)
+	expectBool(t, s.Lookup("r") == nil, true)
+
+	goodProgram(t, shape+"let r := 1; let a := match Shape.Empty { Circle(r) => r else => 0.0 };")
+	goodProgram(t, shape+"let f := fn (n: Int): Int { return match Shape.Empty { Empty => self(n) else => n }; };")
+	badProgram(t, "let a := match 5 { A => 1 };", "(1:16) cannot match on type 'Int'")
+	badProgram(t, shape+"let a := match Shape.Empty { Circle => 1 Rect => 2 };",
+		"(1:64) match is not exhaustive, missing 'Empty'")
+	badProgram(t, shape+"let a := match Shape.Empty { Empty => 1 };",
+		"(1:64) match is not exhaustive, missing 'Circle', 'Rect'")
+	badProgram(t, shape+"let a := match Shape.Empty { Square => 1 else => 2 };",
+		"(1:84) 'Shape' has no variant 'Square'")
+	badProgram(t, shape+"let a := match Shape.Empty { Empty => 1 Empty => 2 else => 3 };",
+		"(1:95) variant 'Empty' is already matched")
+	badProgram(t, shape+"let a := match Shape.Empty { Rect(w) => 1 else => 2 };",
+		"(1:84) variant 'Rect' carries 2 values, got 1 bindings")
+	badProgram(t, shape+"let a := match Shape.Empty { else => 1 Empty => 2 };",
+		"(1:94) unreachable match arm")
+	badProgram(t, shape+"let a := match Shape.Empty { Empty => 1 else => \"a\" };",
+		"(1:103) expected 'Int', got 'Str'")
+	badProgram(t, shape+"let a := match Shape.Empty { Circle(r) => 1 else => r };",
+		"(1:107) variable 'r' was used before it was declared")
+}
+
+func TestCheckFlow(t *testing.T) {
+	goodProgram(t, "let f := fn (): Int { return 1; };")
+	goodProgram(t, "let f := fn (): Void {};")
+	goodProgram(t, "let f := fn (a: Bool): Int { if a { return 1; } else { return 2; }; };")
+	goodProgram(t, "let f := fn (a: Int): Int { if a < 0 { return 1; } else if a > 0 { return 2; } else { return 3; }; };")
+	goodProgram(t, "let f := fn (): Int { while true { }; };")
+	goodProgram(t, "let f := fn (): Int { while true { while true { break; }; }; };")
+	goodProgram(t, "let f := fn (a: Bool): Int { while a { return 1; }; return 2; };")
+	goodProgram(t, "while true { if true { break; } else { continue; }; };")
+	badProgram(t, "let f := fn (): Int { };", "(1:23) missing return of type 'Int' at end of function")
+	badProgram(t, "let f := fn (a: Bool): Int { if a { return 1; }; };",
+		"(1:50) missing return of type 'Int' at end of function")
+	badProgram(t, "let f := fn (a: Bool): Int { if a { return 1; } else if a { return 2; }; };",
+		"(1:74) missing return of type 'Int' at end of function")
+	badProgram(t, "let f := fn (): Int { while true { break; }; };",
+		"(1:46) missing return of type 'Int' at end of function")
+	badProgram(t, "let f := fn (): Int { while true { if true { break; }; }; };",
+		"(1:59) missing return of type 'Int' at end of function")
+	badProgram(t, "let f := fn (): Int { let g := fn (): Int { return 1; }; };",
+		"(1:58) missing return of type 'Int' at end of function")
+	badProgram(t, "let f := fn (): Int { return 1; let a := 2; };", "(1:33) unreachable code")
+	badProgram(t, "let f := fn (a: Bool): Void { if a { return; } else { return; }; a := true; };",
+		"(1:66) unreachable code")
+	badProgram(t, "while true { break; let a := 1; };", "(1:21) unreachable code")
+	badProgram(t, "while true { if true { continue; } else { break; }; let a := 1; };", "(1:53) unreachable code")
+}
+
+func TestCheckBlockScopes(t *testing.T) {
+	goodProgram(t, "if true { let a := 1; }; let a := \"abc\";")
+	goodProgram(t, "let a := 1; if true { let a := \"abc\"; a := \"def\"; }; a := 2;")
+	goodProgram(t, "let a := 1; let f := fn (a: Str): Void { let b := a; };")
+	goodProgram(t, "while true { let a := 1; if a > 0 { let a := true; }; break; };")
+	badProgram(t, "if true { let a := 1; }; a := 2;", "(1:26) 'a' cannot be assigned before it is declared")
+	badProgram(t, "if true {} else { let a := 1; }; a := 2;", "(1:34) 'a' cannot be assigned before it is declared")
+	badProgram(t, "while true { let a := 1; break; }; a := 2;", "(1:36) 'a' cannot be assigned before it is declared")
+	badProgram(t, "let a := 1; let a := 2;", "(1:17) 'a' has already been declared")
+	badProgram(t, "if true { let a := 1; let a := 2; };", "(1:27) 'a' has already been declared")
+	badProgram(t, "let f := fn (a: Int, a: Int): Void {};", "(1:22) 'a' has already been declared")
+}
+
 func TestCheckIfStmt(t *testing.T) {
 	goodProgram(t, "if true {};")
 	badProgram(t, "if 123 {};", "(1:4) condition must resolve to a boolean")
+	goodProgram(t, "if true {} else {};")
+	goodProgram(t, "if true {} else if false {} else {};")
+	badProgram(t, "if true {} else if 123 {};", "(1:20) condition must resolve to a boolean")
+	badProgram(t, "if true {} else { a := 1; };", "(1:19) 'a' cannot be assigned before it is declared")
+}
+
+func TestCheckWhileStmt(t *testing.T) {
+	goodProgram(t, "while true { break; };")
+	goodProgram(t, "let a := 0; while a < 10 { a := a + 1; continue; };")
+	badProgram(t, "while 123 {};", "(1:7) condition must resolve to a boolean")
+	badProgram(t, "while true { a := 1; };", "(1:14) 'a' cannot be assigned before it is declared")
 }
 
 func TestCheckReturnStmt(t *testing.T) {
@@ -124,14 +313,14 @@ func TestCheckExpr(t *testing.T) {
 	expectNthXScopeError(t, s, 0, "(1:32) cannot use void types in an expression")
 	expectBool(t, s.Lookup("a").IsError(), true)
 
-	prog, _ = ParseString("let a := -5;")
+	prog, _ = ParseString("let a := -true;")
 	s = checkProgram(makeScope(nil), prog)
-	expectNthXScopeError(t, s, 0, "(1:10) unknown expression type")
+	expectNthXScopeError(t, s, 0, "(1:10) operator '-' does not support Bool")
 	expectBool(t, s.Lookup("a").IsError(), true)
 }
 
 func TestCheckFunctionExpr(t *testing.T) {
-	prog, _ := ParseString("let f := fn (a: Int): Int { };")
+	prog, _ := ParseString("let f := fn (a: Int): Int { return a; };")
 	s := checkProgram(makeScope(nil), prog)
 	expectNoXScopeErrors(t, s)
 	expectEquivalentType(t, s.Lookup("f"), types.Function{
@@ -140,6 +329,22 @@ func TestCheckFunctionExpr(t *testing.T) {
 	})
 }
 
+func TestCheckAssignability(t *testing.T) {
+	goodProgram(t, "let apply := fn (f: (Int) => Any): Void {}; apply(fn (n: Int): Int { return n; });")
+	goodProgram(t, "let apply := fn (f: (Int) => Int): Void {}; apply(fn (n: Any): Int { return 1; });")
+	goodProgram(t, "let f: (Int) => Int? := fn (n: Int): Int { return n; };")
+	goodProgram(t, "let f := fn (): [Int?] { return [1, 2]; };")
+	goodProgram(t, "let a: Int? := 1; let b := [1, a, 3]; let c: [Int?] := b;")
+	goodProgram(t, "let a: [Any] := [1]; a := [\"abc\"];")
+	badProgram(t, "let apply := fn (f: (Int) => Int): Void {}; apply(fn (n: Int): Any { return n; });",
+		"(1:51) expected '(Int) => Int', got '(Int) => Any'")
+	badProgram(t, "let apply := fn (f: (Any) => Int): Void {}; apply(fn (n: Int): Int { return n; });",
+		"(1:51) expected '(Any) => Int', got '(Int) => Int'")
+	badProgram(t, "let f: (Int) => Void := fn (n: Int): Int { return n; };",
+		"(1:25) '(Int) => Void' cannot be assigned type '(Int) => Int'")
+	badProgram(t, "let a: [Int] := [1]; let b: [Any] := a; a := b;", "(1:46) '[Int]' cannot be assigned type '[Any]'")
+}
+
 func TestCheckDispatchExpr(t *testing.T) {
 	good := func(source string, name string, typ types.Type) {
 		t.Helper()
@@ -198,6 +403,73 @@ func TestCheckDispatchExpr(t *testing.T) {
 		}},
 		Ret: types.Ident{Name: "Int"},
 	}, "(1:5) expected 'Int', got 'Str'", "(1:10) expected 'Int', got 'Str'")
+
+	good("f(1); f(none);", "f", types.Function{
+		Params: types.Tuple{Children: []types.Type{
+			types.Optional{Child: types.BuiltinInt},
+		}},
+		Ret: types.Void{},
+	})
+	bad("f(none);", "f", types.Function{
+		Params: types.Tuple{Children: []types.Type{
+			types.BuiltinInt,
+		}},
+		Ret: types.Void{},
+	}, "(1:3) expected 'Int', got 'None'")
+}
+
+func TestCheckGenericFunctions(t *testing.T) {
+	prog, _ := ParseString("let id := fn<T>(x: T): T { return x; };")
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectString(t, s.Lookup("id").String(), "<T>(T) => T")
+
+	prog, _ = ParseString(`
+		let id := fn<T>(x: T): T { return x; };
+		let a := id(5);
+		let b := id("abc");
+		let c := id([true]);
+		let wrap := fn<T, U>(x: T, f: (T) => U): [U] { return [f(x)]; };
+		let d := wrap(1, fn (n: Int): Str { return "x"; });
+		let first := fn<T>(xs: [T]): T? { return xs[0]; };
+		let e := first(["a"]);
+		let pick := fn<T>(a: T?, b: T): T { return a ?? b; };
+		let f := pick(none, 1);`)
+	s = checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.BuiltinInt)
+	expectEquivalentType(t, s.Lookup("b"), types.BuiltinStr)
+	expectEquivalentType(t, s.Lookup("c"), types.List{Child: types.BuiltinBool})
+	expectEquivalentType(t, s.Lookup("d"), types.List{Child: types.BuiltinStr})
+	expectEquivalentType(t, s.Lookup("e"), types.Optional{Child: types.BuiltinStr})
+	expectEquivalentType(t, s.Lookup("f"), types.BuiltinInt)
+
+	goodProgram(t, "let twice := fn<T>(x: T): [T] { let id := fn<T>(y: T): T { return y; }; return [id(x), x]; };")
+	goodProgram(t, "let f := fn<T, U>(a: T, b: U): T { if false { b := self(b, a); } return a; };")
+	badProgram(t, "let id := fn<T>(x: T): T { return 5; };", "(1:35) expected to return 'T', got 'Int'")
+	badProgram(t, "let f := fn<T>(x: T): T { return x + x; };", "(1:36) operator '+' does not support T and T")
+	badProgram(t, "let f := fn<T>(a: T, b: T): T { return a; }; f(1, \"a\");", "(1:51) expected 'Int', got 'Str'")
+	badProgram(t, "let f := fn<T>(): T? { return none; }; f();", "(1:40) cannot infer type parameter 'T'")
+	badProgram(t, "let f := fn<T>(x: T?): Void {}; f(none);", "(1:33) cannot infer type parameter 'T'")
+	badProgram(t, "let f := fn<Int>(x: Int): Int { return x; };", "(1:13) cannot use builtin type 'Int' as a type parameter")
+	badProgram(t, "let f := fn<T>(x: T): T { return x; }; let g := fn (y: T): Void {};", "(1:56) unknown type 'T'")
+}
+
+func TestCheckGenericLibraryFunctions(t *testing.T) {
+	lib := MakeLibrary("lists")
+	lib.Function("last", types.Function{
+		Params:     types.Tuple{Children: []types.Type{types.List{Child: types.Var{Name: "T"}}}},
+		Ret:        types.Optional{Child: types.Var{Name: "T"}},
+		TypeParams: []types.Var{{Name: "T"}},
+	}, func(args []Object) (Object, error) { return nil, nil })
+
+	ast, _ := ParseString(`use "lists"; let a := lists.last([1, 2]); let b := lists.last(["x"]);`)
+	mod, _ := Link("", ast, map[string]Module{"lists": lib.Module("lists")})
+	Check(mod)
+	s := mod.(*ModuleVirtual).scope
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.Optional{Child: types.BuiltinInt})
+	expectEquivalentType(t, s.Lookup("b"), types.Optional{Child: types.BuiltinStr})
 }
 
 func TestCheckAssignExpr(t *testing.T) {
@@ -234,6 +506,47 @@ func TestCheckAssignExpr(t *testing.T) {
 	bad("a := 123;", "b", types.BuiltinStr, "(1:1) 'a' cannot be assigned before it is declared")
 }
 
+func TestCheckAnnotatedDeclaration(t *testing.T) {
+	goodProgram(t, "let a: Int := 123;")
+	goodProgram(t, "let a: Int? := 123; a := none;")
+	goodProgram(t, "let a: Int? := none; let b := a ?? 0;")
+	goodProgram(t, "let a: [Int] := []; a := [1, 2];")
+	goodProgram(t, "let a: [Int]? := []; let b: [Int] := a ?? [1];")
+	goodProgram(t, "let a: [[Int]] := [[]]; let b: [Int] := a[0] ?? [2];")
+	goodProgram(t, "let a: [[Int]?] := [[]];")
+	goodProgram(t, "let a: [Int?] := [1, none];")
+	goodProgram(t, "let a: [[Int]?] := [[], none, [1]];")
+	goodProgram(t, "let a: [Any] := [1, \"a\"];")
+	goodProgram(t, "let f := fn (a: [Int?]): Int => a.length; f([none, 2]);")
+	goodProgram(t, "let f := fn (): [Int?] { return [1, none]; };")
+	goodProgram(t, "let a: Any := \"abc\";")
+	goodProgram(t, "const a: Int? := 5;")
+	badProgram(t, "let a: Int := \"abc\";", "(1:15) 'Int' cannot be assigned type 'Str'")
+	badProgram(t, "let a: Int? := 123; let b: Int := a;", "(1:35) 'Int' cannot be assigned type 'Int?'")
+	badProgram(t, "let a: Int := [];", "(1:15) cannot determine type from empty list")
+	badProgram(t, "let a: [Int] := [1, \"a\"];", "(1:21) element type Str is not compatible with type Int")
+	badProgram(t, "let a: Itn := 123;", "(1:8) unknown type 'Itn', did you mean 'Int'?")
+}
+
+func TestCheckConstDeclaration(t *testing.T) {
+	goodProgram(t, "const a := 123; let b := a + 1;")
+	goodProgram(t, "const a := 123; let f := fn (): Int { let a := 5; a := 6; return a; };")
+	badProgram(t, "const a := 123; a := 456;", "(1:17) cannot assign to constant 'a'")
+	badProgram(t, "const a := [1]; let f := fn (): Void { a := [2]; };",
+		"(1:40) cannot assign to constant 'a'")
+	badProgram(t, "const a := 1; const a := 2;", "(1:21) cannot redeclare constant 'a'")
+	badProgram(t, "const a := 1; let a := 2;", "(1:19) cannot redeclare constant 'a'")
+
+	prog, _ := ParseString("const a := 1; const b := a + 1; let c := 3;")
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectBool(t, s.IsConst("a"), true)
+	expectBool(t, s.IsConst("b"), true)
+	expectBool(t, s.IsConst("c"), false)
+	expectBool(t, s.lookupConstLiteral("a") != nil, true)
+	expectBool(t, s.lookupConstLiteral("b") == nil, true)
+}
+
 func TestCheckBinaryExpr(t *testing.T) {
 	good := func(left types.Type, oper string, right types.Type, exp types.Type) {
 		t.Helper()
@@ -265,8 +578,45 @@ func TestCheckBinaryExpr(t *testing.T) {
 
 	good(types.BuiltinInt, "+", types.BuiltinInt, types.BuiltinInt)
 	good(types.BuiltinInt, "-", types.BuiltinInt, types.BuiltinInt)
+	good(types.BuiltinInt, "*", types.BuiltinInt, types.BuiltinInt)
+	good(types.BuiltinInt, "/", types.BuiltinInt, types.BuiltinInt)
+	good(types.BuiltinInt, "%", types.BuiltinInt, types.BuiltinInt)
+	good(types.BuiltinFloat, "+", types.BuiltinFloat, types.BuiltinFloat)
+	good(types.BuiltinFloat, "/", types.BuiltinFloat, types.BuiltinFloat)
+	good(types.BuiltinFloat, "<", types.BuiltinFloat, types.BuiltinBool)
+	good(types.BuiltinFloat, "==", types.BuiltinFloat, types.BuiltinBool)
+	good(types.BuiltinInt, "==", types.BuiltinInt, types.BuiltinBool)
+	good(types.BuiltinStr, "!=", types.BuiltinStr, types.BuiltinBool)
+	good(types.BuiltinBool, "==", types.BuiltinBool, types.BuiltinBool)
+	good(types.List{Child: types.BuiltinInt}, "==", types.List{Child: types.BuiltinInt}, types.BuiltinBool)
+	good(types.BuiltinBool, "&&", types.BuiltinBool, types.BuiltinBool)
+	good(types.BuiltinBool, "||", types.BuiltinBool, types.BuiltinBool)
 
 	s := makeScope(nil)
+	s.AddLocal("a", types.BuiltinInt)
+	s.AddLocal("b", types.BuiltinStr)
+	bad("let c := a == b;", s,
+		"(1:12) operator '==' does not support Int and Str")
+
+	s = makeScope(nil)
+	s.AddLocal("a", types.BuiltinInt)
+	s.AddLocal("b", types.BuiltinFloat)
+	bad("let c := a + b;", s,
+		"(1:12) operator '+' does not support Int and Float")
+
+	s = makeScope(nil)
+	s.AddLocal("a", types.BuiltinFloat)
+	s.AddLocal("b", types.BuiltinFloat)
+	bad("let c := a % b;", s,
+		"(1:12) operator '%' does not support Float and Float")
+
+	s = makeScope(nil)
+	s.AddLocal("a", types.Any{})
+	s.AddLocal("b", types.BuiltinInt)
+	bad("let c := a != b;", s,
+		"(1:12) operator '!=' does not support Any and Int")
+
+	s = makeScope(nil)
 	s.AddLocal("b", types.BuiltinInt)
 	bad("let c := a + b;", s,
 		"(1:10) variable 'a' was used before it was declared")
@@ -288,11 +638,127 @@ func TestCheckBinaryExpr(t *testing.T) {
 	expectBool(t, typ.IsError(), true)
 }
 
+func TestCheckTypeTests(t *testing.T) {
+	goodProgram(t, "let f := fn (a: Any): Int? { return a as Int; };")
+	goodProgram(t, "let f := fn (a: Any): Int? { return a as Int?; };")
+	goodProgram(t, "let f := fn (a: Any): Bool { return a is [Str]; };")
+	goodProgram(t, "let f := fn (a: Any): Int { if a is Int { return a + 1; }; return 0; };")
+	goodProgram(t, "let f := fn (a: Any): Int { if a is Int && a > 0 { return a; }; return 0; };")
+	goodProgram(t, "let f := fn (a: Int?): Int { if a is Int { return a; }; return 0; };")
+	goodProgram(t, "let f := fn (a: Int): Any { return a; }; let b := f(1) as Int;")
+	goodProgram(t, "let a := typeof 123; a := \"Str\";")
+	badProgram(t, "let f := fn (a: Any): Int { if a is Int {}; return a; };", "(1:52) expected to return 'Int', got 'Any'")
+	badProgram(t, "let f := fn (a: Any): Int { if !(a is Int) { return a; }; return 0; };",
+		"(1:53) expected to return 'Int', got 'Any'")
+	badProgram(t, "let a := 123 as Str;", "(1:10) type 'Int' can never be 'Str'")
+	badProgram(t, "let f := fn (a: Any): Bool { return a is (Int) => Int; };",
+		"(1:42) cannot check for type '(Int) => Int' at runtime")
+	badProgram(t, "let f := fn (a: Any): Bool { return a is Itn; };", "(1:42) unknown type 'Itn', did you mean 'Int'?")
+	badProgram(t, "let a := typeof b;", "(1:17) variable 'b' was used before it was declared")
+}
+
+func TestCheckUnaryExpr(t *testing.T) {
+	prog, _ := ParseString("let a := !true;")
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.BuiltinBool)
+
+	prog, _ = ParseString("let a := !!(1 < 2);")
+	s = checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.BuiltinBool)
+
+	prog, _ = ParseString("let a := -(+5);")
+	s = checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.BuiltinInt)
+
+	prog, _ = ParseString("let a := !5;")
+	s = checkProgram(makeScope(nil), prog)
+	expectNthXScopeError(t, s, 0, "(1:10) operator '!' does not support Int")
+	expectBool(t, s.Lookup("a").IsError(), true)
+
+	prog, _ = ParseString("let a := !b;")
+	s = checkProgram(makeScope(nil), prog)
+	expectNthXScopeError(t, s, 0, "(1:11) variable 'b' was used before it was declared")
+	expectBool(t, s.Lookup("a").IsError(), true)
+}
+
+func TestCheckNoneExpr(t *testing.T) {
+	s := makeScope(nil)
+	typ := checkNoneExpr(s, &NoneExpr{Tok: nop})
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, typ, types.None{})
+}
+
+func TestCheckOptionals(t *testing.T) {
+	optInt := types.Optional{Child: types.BuiltinInt}
+
+	good := func(source string, exp types.Type) {
+		t.Helper()
+		prog, _ := ParseString(source)
+		s := makeScope(nil)
+		s.AddLocal("x", optInt)
+		s.AddLocal("y", optInt)
+		checkProgram(s, prog)
+		expectNoXScopeErrors(t, s)
+		if exp != nil {
+			expectEquivalentType(t, s.Lookup("a"), exp)
+		}
+	}
+
+	bad := func(source string, errs ...string) {
+		t.Helper()
+		prog, _ := ParseString(source)
+		s := makeScope(nil)
+		s.AddLocal("x", optInt)
+		s.AddLocal("y", optInt)
+		checkProgram(s, prog)
+		for n, err := range errs {
+			expectNthXScopeError(t, s, n, err)
+		}
+	}
+
+	good("let a := x ?? 0;", types.BuiltinInt)
+	good("let a := x ?? y;", optInt)
+	good("let a := x ?? y ?? 5;", types.BuiltinInt)
+	good("let a := x == none;", types.BuiltinBool)
+	good("let a := none != x;", types.BuiltinBool)
+	good("let a := x == 5;", types.BuiltinBool)
+	good("x := none; x := 5; y := x;", nil)
+	bad("let a := x ?? true;", "(1:12) operator '??' does not support Int? and Bool")
+	bad("let a := 5 ?? 6;", "(1:12) operator '??' does not support Int and Int")
+	bad("let a := x == true;", "(1:12) operator '==' does not support Int? and Bool")
+	bad("let a := x + 1;", "(1:12) operator '+' does not support Int? and Int")
+
+	// Narrowing inside of conditional blocks
+	good("let a := 0; if x != none { a := x + 1; };", nil)
+	good("let a := 0; if none != x { a := x + 1; };", nil)
+	good("let a := 0; if x == none { } else { a := x + 1; };", nil)
+	good("let a := 0; if !(x == none) { a := x + 1; };", nil)
+	good("let a := 0; if x != none && y != none { a := x + y; };", nil)
+	good("let a := 0; if x == none || y == none { } else { a := x + y; };", nil)
+	good("let a := x != none && x > 0;", types.BuiltinBool)
+	good("let a := x == none || x > 0;", types.BuiltinBool)
+	good("let a := 0; while x != none { a := x; x := none; };", nil)
+	good("let f := fn (): Int { if x != none { return x; } return 0; };", nil)
+	bad("let a := 0; if x != none { }; a := x + 1;",
+		"(1:38) operator '+' does not support Int? and Int")
+	bad("let a := 0; if x == none { a := x + 1; };",
+		"(1:35) operator '+' does not support Int? and Int")
+	bad("let a := 0; if x != none || y != none { a := x + 1; };",
+		"(1:48) operator '+' does not support Int? and Int")
+	bad("let a := 0; if x != none { x := none; a := x + 1; };",
+		"(1:46) operator '+' does not support Int? and Int")
+	bad("let a := x == none && x > 0;",
+		"(1:25) operator '>' does not support Int? and Int")
+}
+
 func TestCheckListExpr(t *testing.T) {
 	good := func(expr *ListExpr, exp types.Type) {
 		t.Helper()
 		s := makeScope(nil)
-		got := checkListExpr(s, expr)
+		got := checkListExpr(s, expr, nil)
 		expectNoXScopeErrors(t, s)
 		expectEquivalentType(t, got, exp)
 	}
@@ -300,7 +766,7 @@ func TestCheckListExpr(t *testing.T) {
 	bad := func(expr *ListExpr, exp string) {
 		t.Helper()
 		s := makeScope(nil)
-		got := checkListExpr(s, expr)
+		got := checkListExpr(s, expr, nil)
 		expectNthXScopeError(t, s, 0, exp)
 		expectEquivalentType(t, got, types.Error{})
 	}
@@ -357,6 +823,12 @@ func TestCheckSubscriptExpr(t *testing.T) {
 	expectNthXScopeError(t, s, 0, "(2:9) subscript operator does not support Str[Str]")
 	expectBool(t, typ.IsError(), true)
 
+	s = makeScope(nil)
+	expr = &SubscriptExpr{ListLike: list, Index: badIndex}
+	typ = checkSubscriptExpr(s, expr, defaultBinopsLUT)
+	expectNthXScopeError(t, s, 0, "(2:9) subscript operator does not support [Int][Str]")
+	expectBool(t, typ.IsError(), true)
+
 	s = makeScope(nil)
 	str = &StringExpr{Tok: makeTok(4, 2), Val: "foo"}
 	expr = &SubscriptExpr{ListLike: str, Index: index}
@@ -376,6 +848,60 @@ func TestCheckAccessExpr(t *testing.T) {
 
 		good("let a := [1,2]; let b := a.length();")
 	*/
+
+	prog, _ := ParseString("let p := {x: 1, y: {z: true}}; let a := p.y.z;")
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.BuiltinBool)
+
+	badProgram(t, "let p := {x: 1}; let a := p.y;", "(1:29) type {x:Int} does not have member 'y'")
+	badProgram(t, "let p := 5; let a := p.y;", "(1:24) type Int does not have member 'y'")
+}
+
+func TestCheckStructExpr(t *testing.T) {
+	prog, _ := ParseString(`let a := {x: 1, y: "abc", z: [true]};`)
+	s := checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.Struct{Fields: []struct {
+		Name string
+		Type types.Type
+	}{
+		{"x", types.BuiltinInt},
+		{"y", types.BuiltinStr},
+		{"z", types.List{Child: types.BuiltinBool}},
+	}})
+
+	prog, _ = ParseString("let a := {};")
+	s = checkProgram(makeScope(nil), prog)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, s.Lookup("a"), types.Struct{})
+
+	goodProgram(t, "let f := fn (p: {y: Int x: Int}): Int { return p.x; }; f({x: 1, y: 2});")
+	badProgram(t, "let a := {x: b};", "(1:14) variable 'b' was used before it was declared")
+	badProgram(t, "let f := fn (p: {x: Int}): Void {}; f({x: true});",
+		"(1:39) expected '{x:Int}', got '{x:Bool}'")
+	badProgram(t, "let f := fn (p: {x: Int}): Void {}; f({x: 1, y: 2});",
+		"(1:39) expected '{x:Int}', got '{x:Int y:Int}'")
+}
+
+func TestCheckFieldAssignExpr(t *testing.T) {
+	goodProgram(t, "let p := {x: 1}; p.x := 2;")
+	goodProgram(t, "let p := {x: {y: 1}}; p.x.y := 2;")
+	goodProgram(t, "let p := {x: [1][0]}; p.x := none; p.x := 5;")
+	goodProgram(t, "let p := {x: 1}; let a := (p.x := 2) + 1;")
+	badProgram(t, "let p := {x: 1}; p.x := true;", "(1:25) 'Int' cannot be assigned type 'Bool'")
+	badProgram(t, "let p := {x: 1}; p.y := 2;", "(1:20) type {x:Int} does not have member 'y'")
+	badProgram(t, "p.x := 2;", "(1:1) variable 'p' was used before it was declared")
+
+	lib := MakeLibrary("io")
+	lib.Function("print", types.Function{Params: types.Tuple{}, Ret: types.Void{}}, nil)
+	ast, _ := ParseString(`use "io"; io.print := 5;`)
+	mod, _ := Link("", ast, map[string]Module{"io": lib.Module("io")})
+	errs := Check(mod)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %d", len(errs))
+	}
+	expectAnError(t, errs[0], "(1:11) cannot assign to members of module 'io'")
 }
 
 func TestCheckSelfExpr(t *testing.T) {
@@ -411,6 +937,14 @@ func TestCheckNumberExpr(t *testing.T) {
 	expectEquivalentType(t, typ, types.BuiltinInt)
 }
 
+func TestCheckFloatExpr(t *testing.T) {
+	s := makeScope(nil)
+	expr := &FloatExpr{Tok: nop, Val: 1.5}
+	typ := checkFloatExpr(s, expr)
+	expectNoXScopeErrors(t, s)
+	expectEquivalentType(t, typ, types.BuiltinFloat)
+}
+
 func TestCheckStringExpr(t *testing.T) {
 	s := makeScope(nil)
 	expr := &StringExpr{Tok: nop, Val: "abc"}
@@ -431,7 +965,7 @@ func TestConvertTypeSig(t *testing.T) {
 	var note TypeNote
 
 	note = TypeNoteVoid{Tok: nop}
-	expectEquivalentType(t, convertTypeNote(note), types.Void{})
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.Void{})
 
 	note = TypeNoteFunction{
 		Params: TypeNoteTuple{Tok: nop, Elems: []TypeNote{
@@ -440,7 +974,7 @@ func TestConvertTypeSig(t *testing.T) {
 		}},
 		Ret: TypeNoteIdent{Tok: nop, Name: "Str"},
 	}
-	expectEquivalentType(t, convertTypeNote(note), types.Function{
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.Function{
 		Params: types.Tuple{Children: []types.Type{
 			types.Ident{Name: "Int"},
 			types.Ident{Name: "Bool"},
@@ -455,7 +989,7 @@ func TestConvertTypeSig(t *testing.T) {
 		}},
 		Ret: TypeNoteVoid{},
 	}
-	expectEquivalentType(t, convertTypeNote(note), types.Function{
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.Function{
 		Params: types.Tuple{Children: []types.Type{
 			types.Ident{Name: "Int"},
 			types.Ident{Name: "Bool"},
@@ -467,22 +1001,22 @@ func TestConvertTypeSig(t *testing.T) {
 		TypeNoteIdent{Tok: nop, Name: "Int"},
 		TypeNoteIdent{Tok: nop, Name: "Bool"},
 	}}
-	expectEquivalentType(t, convertTypeNote(note), types.Tuple{Children: []types.Type{
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.Tuple{Children: []types.Type{
 		types.Ident{Name: "Int"},
 		types.Ident{Name: "Bool"},
 	}})
 
 	note = TypeNoteList{Tok: nop, Child: TypeNoteIdent{Tok: nop, Name: "Int"}}
-	expectEquivalentType(t, convertTypeNote(note), types.List{Child: types.Ident{Name: "Int"}})
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.List{Child: types.Ident{Name: "Int"}})
 
 	note = TypeNoteOptional{Tok: nop, Child: TypeNoteIdent{Tok: nop, Name: "Int"}}
-	expectEquivalentType(t, convertTypeNote(note), types.Optional{Child: types.Ident{Name: "Int"}})
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.Optional{Child: types.Ident{Name: "Int"}})
 
 	note = TypeNoteIdent{Tok: nop, Name: "Int"}
-	expectEquivalentType(t, convertTypeNote(note), types.Ident{Name: "Int"})
+	expectEquivalentType(t, convertTypeNote(makeScope(nil), note), types.Ident{Name: "Int"})
 
 	note = nil
-	expectBool(t, convertTypeNote(note) == nil, true)
+	expectBool(t, convertTypeNote(makeScope(nil), note) == nil, true)
 }
 
 func TestConvertTypeNote(t *testing.T) {
@@ -518,8 +1052,14 @@ func TestConvertTypeNote(t *testing.T) {
 	note = TypeNoteOptional{Tok: nop, Child: blah}
 	expectConversion(t, note, "Blah?")
 
+	note = TypeNoteStruct{Tok: nop, Fields: []TypeNoteField{
+		{&IdentExpr{nop, "a"}, blob},
+		{&IdentExpr{nop, "b"}, TypeNoteList{Tok: nop, Child: blub}},
+	}}
+	expectConversion(t, note, "{a:Blob b:[Blub]}")
+
 	note = nil
-	got := convertTypeNote(note)
+	got := convertTypeNote(makeScope(nil), note)
 	if got != nil {
 		t.Errorf("Expected '%v', got '%v'", nil, got)
 	}
@@ -543,7 +1083,7 @@ func badProgram(t *testing.T, src string, msg string) {
 
 func expectConversion(t *testing.T, note TypeNote, exp string) {
 	t.Helper()
-	got := convertTypeNote(note)
+	got := convertTypeNote(makeScope(nil), note)
 	if got.String() != exp {
 		t.Errorf("Expected '%s', got '%v'", exp, got)
 	}
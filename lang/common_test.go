@@ -93,3 +93,39 @@ func expectAnError(t *testing.T, err error, msg string) {
 		t.Errorf("Expected '%s', got '%s'", msg, err)
 	}
 }
+
+// makePrintLibrary builds an "io" library whose print function records the
+// string form of each value it's given
+func makePrintLibrary(out *[]string) Module {
+	lib := MakeLibrary("io")
+	lib.Function("print", types.Function{
+		Params: types.Tuple{Children: []types.Type{types.Any{}}},
+		Ret:    types.Void{},
+	}, func(args []Object) (Object, error) {
+		*out = append(*out, args[0].String())
+		return ObjectNone{}, nil
+	})
+	return lib.Module("io")
+}
+
+// compileProgram parses, links, checks and compiles a program at the given
+// optimization level, stopping the test at the first error
+func compileProgram(tb testing.TB, path string, src string, stdlib map[string]Module, level OptLevel) *ModuleVirtual {
+	tb.Helper()
+	ast, errs := ParseString(src)
+	if len(errs) > 0 {
+		tb.Fatal(errs[0])
+	}
+
+	mod, errs := Link(path, ast, stdlib)
+	if len(errs) > 0 {
+		tb.Fatal(errs[0])
+	}
+
+	if errs = Check(mod); len(errs) > 0 {
+		tb.Fatal(errs[0])
+	}
+
+	Compile(mod, level)
+	return mod.(*ModuleVirtual)
+}
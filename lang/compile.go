@@ -1,19 +1,29 @@
 package lang
 
-import "fmt"
+import (
+	"fmt"
+)
 
-func Compile(mod Module) Bytecode {
+// Compile translates a checked module and its virtual dependencies into
+// bytecode at the same optimization level and keeps the bytecode with each
+// module so it can be run. The module's own bytecode is returned
+func Compile(mod Module, level OptLevel) Bytecode {
 	if mod.IsNative() == false {
-		btc := compileModule(mod.(*ModuleVirtual))
-		mod.(*ModuleVirtual).bytecode = &btc
-		return btc
+		for _, dep := range orderModules(mod.(*ModuleVirtual), nil) {
+			btc := compileModule(dep)
+			if level > OptNone {
+				btc = optimize(btc)
+			}
+			dep.bytecode = &btc
+		}
+		return *mod.(*ModuleVirtual).bytecode
 	}
 
 	return Bytecode{}
 }
 
 func compileModule(mod *ModuleVirtual) (blob Bytecode) {
-	for name, _ := range mod.scope.Local {
+	for _, name := range slotNames(mod.scope) {
 		blob.write(InstrReserve{name})
 	}
 	blob.append(compileRootStmts(mod, mod.structure.Stmts))
@@ -47,21 +57,55 @@ func compileRootStmt(mod *ModuleVirtual, stmt Stmt) Bytecode {
 func compileUseStmt(mod *ModuleVirtual, stmt *UseStmt) Bytecode {
 	blob := compileStringExpr(mod.scope, stmt.Path)
 	blob.write(InstrLoadMod{})
+	for _, dep := range mod.dependencies {
+		if dep.relative == stmt.Path.Val {
+			blob.write(compileStore(mod.scope, mod.scope, dep.alias))
+			break
+		}
+	}
+	blob.locate(mod.path, stmt.Start())
 	return blob
 }
 
+// modulePath returns the path of the module a scope belongs to
+func modulePath(s *Scope) string {
+	if s.Module == nil {
+		return ""
+	}
+	return s.Module.path
+}
+
+// compileStmt compiles a statement and records the statement's location for
+// any instructions not already attributed to a nested node
 func compileStmt(s *Scope, stmt Stmt) Bytecode {
+	blob := compileStmtNode(s, stmt)
+	blob.locate(modulePath(s), stmt.Start())
+	return blob
+}
+
+func compileStmtNode(s *Scope, stmt Stmt) Bytecode {
 	switch stmt := stmt.(type) {
 	case *PubStmt:
 		return compilePubStmt(s, stmt)
 	case *IfStmt:
 		return compileIfStmt(s, stmt)
+	case *WhileStmt:
+		return compileWhileStmt(s, stmt)
+	case *BreakStmt:
+		return compileBreakStmt(s, stmt)
+	case *ContinueStmt:
+		return compileContinueStmt(s, stmt)
 	case *DeclarationStmt:
 		return compileDeclarationStmt(s, stmt)
 	case *ReturnStmt:
 		return compileReturnStmt(s, stmt)
 	case *ExprStmt:
 		return compileExprStmt(s, stmt)
+	case *TypeStmt:
+		// Type declarations only exist at check time
+		return Bytecode{}
+	case *EnumStmt:
+		return compileEnumStmt(s, stmt)
 	default:
 		panic(fmt.Sprintf("cannot compile %T", stmt))
 	}
@@ -75,15 +119,139 @@ func compilePubStmt(s *Scope, stmt *PubStmt) Bytecode {
 func compileIfStmt(s *Scope, stmt *IfStmt) Bytecode {
 	blob := compileExpr(s, stmt.Cond)
 	jump := blob.write(InstrNOP{}) // Pending jump to end of if-clause
-	done := blob.append(compileStmts(s, stmt.Clause.Stmts))
+	done := blob.append(compileNestedBlock(s, stmt.Clause))
+
+	if stmt.Else == nil {
+		blob.overwrite(jump, InstrJumpFalse{done})
+		return blob
+	}
+
+	skip := blob.write(InstrNOP{}) // Pending jump to end of else-clause
+	blob.overwrite(jump, InstrJumpFalse{skip + 1})
+
+	switch alt := stmt.Else.(type) {
+	case *IfStmt:
+		done = blob.append(compileIfStmt(s, alt))
+	case *StmtBlock:
+		done = blob.append(compileNestedBlock(s, alt))
+	}
+
+	blob.overwrite(skip, InstrJump{done})
+	return blob
+}
+
+func compileWhileStmt(s *Scope, stmt *WhileStmt) Bytecode {
+	blob := compileExpr(s, stmt.Cond)
+	jump := blob.write(InstrNOP{}) // Pending jump to end of while-clause
+	blob.append(compileNestedBlock(s, stmt.Clause))
+	blob.write(InstrJump{0})
+	done := blob.nextInstrPtr()
 	blob.overwrite(jump, InstrJumpFalse{done})
+
+	// Any break or continue statements within the clause that haven't already
+	// been claimed by a nested loop belong to this loop.
+	for addr := jump + 1; addr < done; addr++ {
+		switch blob.Instructions[addr].(type) {
+		case instrPendingBreak:
+			blob.overwrite(addr, InstrJump{done})
+		case instrPendingContinue:
+			blob.overwrite(addr, InstrJump{0})
+		}
+	}
+
 	return blob
 }
 
-func compileDeclarationStmt(s *Scope, stmt *DeclarationStmt) Bytecode {
-	blob := compileExpr(s, stmt.Expr)
+// compileNestedBlock compiles a block that was checked in its own scope. The
+// block only gets its own runtime frame if it declares any variables
+func compileNestedBlock(s *Scope, block *StmtBlock) (blob Bytecode) {
+	local := s.Children[block]
+	if hasFrame(local) == false {
+		return compileStmts(local, block.Stmts)
+	}
+
+	blob.write(InstrEnterBlock{})
+	for _, name := range slotNames(local) {
+		blob.write(InstrReserve{name})
+	}
+	blob.append(compileStmts(local, block.Stmts))
+	blob.write(InstrLeaveBlock{})
+	return blob
+}
+
+// compileLeaveLoopBlocks leaves every block frame between a break or continue
+// statement and the body of the loop the statement belongs to
+func compileLeaveLoopBlocks(s *Scope) (blob Bytecode) {
+	for scope := s; scope.block; scope = scope.Parent {
+		if hasFrame(scope) {
+			blob.write(InstrLeaveBlock{})
+		}
+
+		if scope.loop {
+			break
+		}
+	}
+	return blob
+}
+
+func compileBreakStmt(s *Scope, stmt *BreakStmt) (blob Bytecode) {
+	blob.append(compileLeaveLoopBlocks(s))
+	blob.write(instrPendingBreak{})
+	return blob
+}
+
+func compileContinueStmt(s *Scope, stmt *ContinueStmt) (blob Bytecode) {
+	blob.append(compileLeaveLoopBlocks(s))
+	blob.write(instrPendingContinue{})
+	return blob
+}
+
+func compileDeclarationStmt(s *Scope, stmt *DeclarationStmt) (blob Bytecode) {
 	name := stmt.Name.Name
-	blob.write(InstrStore{name})
+
+	// Functions are named after the variable they're declared as so that
+	// runtime errors can refer to them
+	if fn, ok := stmt.Expr.(*FunctionExpr); ok {
+		blob = compileNamedFunctionExpr(s, fn, name)
+	} else {
+		blob = compileExpr(s, stmt.Expr)
+	}
+
+	blob.write(compileStore(s, s, name))
+	return blob
+}
+
+// compileEnumStmt builds the struct of variant constructors. Variants that
+// carry values are constructed by calling a function, other variants are
+// created once and shared
+func compileEnumStmt(s *Scope, stmt *EnumStmt) (blob Bytecode) {
+	var names []string
+	for _, variant := range stmt.Variants {
+		tag := variant.Name.Name
+		names = append(names, tag)
+
+		if len(variant.Payload) == 0 {
+			blob.write(InstrCreateVariant{stmt.Name.Name, tag, 0})
+			continue
+		}
+
+		var params []string
+		body := Bytecode{}
+		for i := range variant.Payload {
+			param := fmt.Sprintf("#%d", i)
+			params = append(params, param)
+			body.write(InstrLoad{param, 0, i})
+		}
+		body.write(InstrCreateVariant{stmt.Name.Name, tag, len(params)})
+		body.write(InstrReturn{})
+
+		name := stmt.Name.Name + "." + tag
+		blob.write(InstrPush{&ObjectFunction{name: name, params: params, bytecode: body}})
+		blob.write(InstrCreateClosure{})
+	}
+
+	blob.write(InstrCreateStruct{names})
+	blob.write(compileStore(s, s, stmt.Name.Name))
 	return blob
 }
 
@@ -103,7 +271,20 @@ func compileExprStmt(s *Scope, stmt *ExprStmt) Bytecode {
 	return blob
 }
 
+// compileExpr compiles an expression and records the expression's location
+// for any instructions not already attributed to a nested node. Instructions
+// produced by a binary operator are attributed to the operator
 func compileExpr(s *Scope, expr Expr) Bytecode {
+	blob := compileExprNode(s, expr)
+	if binary, ok := expr.(*BinaryExpr); ok {
+		blob.locate(modulePath(s), binary.Tok.Loc)
+	} else {
+		blob.locate(modulePath(s), expr.Start())
+	}
+	return blob
+}
+
+func compileExprNode(s *Scope, expr Expr) Bytecode {
 	switch expr := expr.(type) {
 	case *FunctionExpr:
 		return compileFunctionExpr(s, expr)
@@ -111,16 +292,38 @@ func compileExpr(s *Scope, expr Expr) Bytecode {
 		return compileDispatchExpr(s, expr)
 	case *AssignExpr:
 		return compileAssignExpr(s, expr)
+	case *FieldAssignExpr:
+		return compileFieldAssignExpr(s, expr)
 	case *BinaryExpr:
 		return compileBinaryExpr(s, expr)
+	case *UnaryExpr:
+		return compileUnaryExpr(s, expr)
+	case *CastExpr:
+		return compileCastExpr(s, expr)
+	case *IsExpr:
+		return compileIsExpr(s, expr)
+	case *TypeofExpr:
+		return compileTypeofExpr(s, expr)
 	case *AccessExpr:
 		return compileAccessExpr(s, expr)
 	case *IdentExpr:
 		return compileIdentExpr(s, expr)
 	case *SelfExpr:
 		return compileSelfExpr(s, expr)
+	case *ListExpr:
+		return compileListExpr(s, expr)
+	case *StructExpr:
+		return compileStructExpr(s, expr)
+	case *MatchExpr:
+		return compileMatchExpr(s, expr)
+	case *SubscriptExpr:
+		return compileSubscriptExpr(s, expr)
 	case *NumberExpr:
 		return compileNumberExpr(s, expr)
+	case *FloatExpr:
+		return compileFloatExpr(s, expr)
+	case *NoneExpr:
+		return compileNoneExpr(s, expr)
 	case *StringExpr:
 		return compileStringExpr(s, expr)
 	case *BooleanExpr:
@@ -130,7 +333,11 @@ func compileExpr(s *Scope, expr Expr) Bytecode {
 	}
 }
 
-func compileFunctionExpr(s *Scope, expr *FunctionExpr) (blob Bytecode) {
+func compileFunctionExpr(s *Scope, expr *FunctionExpr) Bytecode {
+	return compileNamedFunctionExpr(s, expr, "")
+}
+
+func compileNamedFunctionExpr(s *Scope, expr *FunctionExpr, name string) (blob Bytecode) {
 	local := s.Children[expr]
 	var params []string
 	for _, param := range expr.Params {
@@ -138,19 +345,10 @@ func compileFunctionExpr(s *Scope, expr *FunctionExpr) (blob Bytecode) {
 		params = append(params, name)
 	}
 
+	// Parameters occupy the first slots and are filled in by the caller
 	blobBody := Bytecode{}
-	for name := range local.Local {
-		isParam := false
-		for _, param := range expr.Params {
-			if param.Name.Name == name {
-				isParam = true
-				break
-			}
-		}
-
-		if isParam == false {
-			blobBody.write(InstrReserve{name})
-		}
+	for _, name := range slotNames(local)[len(local.params):] {
+		blobBody.write(InstrReserve{name})
 	}
 
 	blobBody.append(compileStmts(local, expr.Block.Stmts))
@@ -158,6 +356,7 @@ func compileFunctionExpr(s *Scope, expr *FunctionExpr) (blob Bytecode) {
 	blobBody.write(InstrReturn{})
 
 	function := &ObjectFunction{
+		name:     name,
 		params:   params,
 		bytecode: blobBody,
 	}
@@ -180,11 +379,27 @@ func compileAssignExpr(s *Scope, expr *AssignExpr) Bytecode {
 	blob := compileExpr(s, expr.Right)
 	name := expr.Left.Name
 	blob.write(InstrCopy{})
-	blob.write(InstrStore{name})
+	blob.write(compileStore(s, s.binding(expr.Left), name))
+	return blob
+}
+
+func compileFieldAssignExpr(s *Scope, expr *FieldAssignExpr) Bytecode {
+	blob := compileExpr(s, expr.Left.Left)
+	blob.append(compileExpr(s, expr.Right))
+	blob.write(InstrStoreAttr{expr.Left.Right.(*IdentExpr).Name})
 	return blob
 }
 
 func compileBinaryExpr(s *Scope, expr *BinaryExpr) Bytecode {
+	switch expr.Oper {
+	case "&&":
+		return compileLogicalExpr(s, expr, false)
+	case "||":
+		return compileLogicalExpr(s, expr, true)
+	case "??":
+		return compileCoalesceExpr(s, expr)
+	}
+
 	blob := compileExpr(s, expr.Left)
 	blob.append(compileExpr(s, expr.Right))
 	switch expr.Oper {
@@ -194,6 +409,14 @@ func compileBinaryExpr(s *Scope, expr *BinaryExpr) Bytecode {
 		blob.write(InstrSub{})
 	case "*":
 		blob.write(InstrMul{})
+	case "/":
+		blob.write(InstrDiv{})
+	case "%":
+		blob.write(InstrRem{})
+	case "==":
+		blob.write(InstrEquals{})
+	case "!=":
+		blob.write(InstrNotEquals{})
 	case "<":
 		blob.write(InstrLT{})
 	case "<=":
@@ -208,14 +431,164 @@ func compileBinaryExpr(s *Scope, expr *BinaryExpr) Bytecode {
 	return blob
 }
 
+// compileLogicalExpr only evaluates the right operand if the left operand
+// doesn't already decide the result. If the left operand is equal to
+// `shortCircuit` the left operand is used as the result.
+func compileLogicalExpr(s *Scope, expr *BinaryExpr, shortCircuit bool) Bytecode {
+	blob := compileExpr(s, expr.Left)
+	blob.write(InstrCopy{})
+	jump := blob.write(InstrNOP{}) // Pending jump past the right operand
+	blob.write(InstrPop{})
+	done := blob.append(compileExpr(s, expr.Right))
+
+	if shortCircuit {
+		blob.overwrite(jump, InstrJumpTrue{done})
+	} else {
+		blob.overwrite(jump, InstrJumpFalse{done})
+	}
+
+	return blob
+}
+
+// compileCoalesceExpr only evaluates the right operand if the left operand
+// is none
+func compileCoalesceExpr(s *Scope, expr *BinaryExpr) Bytecode {
+	blob := compileExpr(s, expr.Left)
+	blob.write(InstrCopy{})
+	blob.write(InstrPush{&ObjectNone{}})
+	blob.write(InstrEquals{})
+	jump := blob.write(InstrNOP{}) // Pending jump past the right operand
+	blob.write(InstrPop{})
+	done := blob.append(compileExpr(s, expr.Right))
+	blob.overwrite(jump, InstrJumpFalse{done})
+	return blob
+}
+
+func compileUnaryExpr(s *Scope, expr *UnaryExpr) Bytecode {
+	blob := compileExpr(s, expr.Expr)
+	switch expr.Oper {
+	case "+":
+		// Unary plus leaves its operand unchanged
+	case "-":
+		blob.write(InstrNeg{})
+	case "!":
+		blob.write(InstrNot{})
+	default:
+		panic(fmt.Sprintf("cannot compile %T", expr))
+	}
+	return blob
+}
+
+func compileCastExpr(s *Scope, expr *CastExpr) Bytecode {
+	blob := compileExpr(s, expr.Expr)
+	blob.write(InstrCast{s.targets[expr]})
+	return blob
+}
+
+func compileIsExpr(s *Scope, expr *IsExpr) Bytecode {
+	blob := compileExpr(s, expr.Expr)
+	blob.write(InstrIsType{s.targets[expr]})
+	return blob
+}
+
+func compileTypeofExpr(s *Scope, expr *TypeofExpr) Bytecode {
+	blob := compileExpr(s, expr.Expr)
+	blob.write(InstrTypeof{})
+	return blob
+}
+
+func compileListExpr(s *Scope, expr *ListExpr) (blob Bytecode) {
+	for _, elem := range expr.Elements {
+		blob.append(compileExpr(s, elem))
+	}
+	blob.write(InstrCreateList{len(expr.Elements)})
+	return blob
+}
+
+func compileStructExpr(s *Scope, expr *StructExpr) (blob Bytecode) {
+	var names []string
+	for _, field := range expr.Fields {
+		blob.append(compileExpr(s, field.Expr))
+		names = append(names, field.Name.Name)
+	}
+	blob.write(InstrCreateStruct{names})
+	return blob
+}
+
+func compileSubscriptExpr(s *Scope, expr *SubscriptExpr) Bytecode {
+	blob := compileExpr(s, expr.ListLike)
+	blob.append(compileExpr(s, expr.Index))
+	blob.write(InstrSubscript{})
+	return blob
+}
+
 func compileAccessExpr(s *Scope, expr *AccessExpr) Bytecode {
 	blob := compileExpr(s, expr.Left)
 	blob.write(InstrLoadAttr{expr.Right.(*IdentExpr).Name})
 	return blob
 }
 
+// compileMatchExpr tests the subject against each arm in order. The checker
+// guarantees that the match is exhaustive so the final arm doesn't need a
+// test unless it's preceded by a wildcard
+func compileMatchExpr(s *Scope, expr *MatchExpr) Bytecode {
+	blob := compileExpr(s, expr.Subject)
+
+	var exits []Address
+	for i, arm := range expr.Arms {
+		local := s.Children[arm]
+
+		if arm.IsWildcard() {
+			blob.write(InstrPop{})
+			blob.append(compileExpr(local, arm.Expr))
+			exits = append(exits, blob.write(InstrNOP{})) // Pending jump to end of match
+			break
+		}
+
+		var test Address
+		last := i == len(expr.Arms)-1
+		if last == false {
+			blob.write(InstrCopy{})
+			test = blob.write(InstrNOP{}) // Pending jump to next arm
+		}
+
+		if len(arm.Bindings) == 0 {
+			blob.write(InstrPop{})
+			blob.append(compileExpr(local, arm.Expr))
+		} else {
+			blob.write(InstrEnterBlock{})
+			for _, name := range slotNames(local) {
+				blob.write(InstrReserve{name})
+			}
+			blob.write(InstrUnpack{len(arm.Bindings)})
+			for j := len(arm.Bindings) - 1; j >= 0; j-- {
+				blob.write(compileStore(local, local, arm.Bindings[j].Name))
+			}
+			blob.append(compileExpr(local, arm.Expr))
+			blob.write(InstrLeaveBlock{})
+		}
+
+		if last == false {
+			exits = append(exits, blob.write(InstrNOP{})) // Pending jump to end of match
+			blob.overwrite(test, InstrJumpNotVariant{arm.Variant.Name, blob.nextInstrPtr()})
+		}
+	}
+
+	done := blob.nextInstrPtr()
+	for _, exit := range exits {
+		blob.overwrite(exit, InstrJump{done})
+	}
+
+	return blob
+}
+
 func compileIdentExpr(s *Scope, expr *IdentExpr) (blob Bytecode) {
-	blob.write(InstrLoad{expr.Name})
+	// Constants initialized with a literal are replaced by that literal
+	if literal, ok := s.literals[expr]; ok {
+		return compileExpr(s, literal)
+	}
+
+	blob.write(compileLoad(s, s.binding(expr), expr.Name))
 	return blob
 }
 
@@ -229,6 +602,16 @@ func compileNumberExpr(s *Scope, expr *NumberExpr) (blob Bytecode) {
 	return blob
 }
 
+func compileFloatExpr(s *Scope, expr *FloatExpr) (blob Bytecode) {
+	blob.write(InstrPush{&ObjectFloat{expr.Val}})
+	return blob
+}
+
+func compileNoneExpr(s *Scope, expr *NoneExpr) (blob Bytecode) {
+	blob.write(InstrPush{&ObjectNone{}})
+	return blob
+}
+
 func compileStringExpr(s *Scope, expr *StringExpr) (blob Bytecode) {
 	blob.write(InstrPush{&ObjectStr{expr.Val}})
 	return blob
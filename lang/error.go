@@ -2,6 +2,7 @@ package lang
 
 import (
 	"fmt"
+	"strings"
 )
 
 // SyntaxError combines a source code location with the resulting error message
@@ -14,3 +15,44 @@ type SyntaxError struct {
 func (err SyntaxError) Error() string {
 	return fmt.Sprintf("%s%s %s", err.Filepath, err.Location, err.Message)
 }
+
+// RuntimeError describes a failure that stopped the evaluation of a program.
+// The stack lists the functions that were being evaluated, starting with the
+// function where the failure occurred and ending with the module
+type RuntimeError struct {
+	Message string
+	Loc     Loc
+	Stack   []StackFrame
+}
+
+func (err RuntimeError) Error() string {
+	if len(err.Stack) > 0 && err.Loc != (Loc{}) {
+		return fmt.Sprintf("%s%s runtime error: %s", err.Stack[0].Module, err.Loc, err.Message)
+	}
+	return fmt.Sprintf("runtime error: %s", err.Message)
+}
+
+// StackTrace describes the functions that were being evaluated when the
+// error occurred, one function per line
+func (err RuntimeError) StackTrace() string {
+	var lines []string
+	for _, frame := range err.Stack {
+		lines = append(lines, "  at "+frame.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// StackFrame describes a function that was being evaluated when a runtime
+// error occurred. Function is empty for code at the top level of a module
+type StackFrame struct {
+	Function string
+	Module   string
+	Loc      Loc
+}
+
+func (frame StackFrame) String() string {
+	if frame.Function == "" {
+		return fmt.Sprintf("%s%s", frame.Module, frame.Loc)
+	}
+	return fmt.Sprintf("%s %s%s", frame.Function, frame.Module, frame.Loc)
+}
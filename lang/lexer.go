@@ -5,41 +5,61 @@ type tokType string
 
 // Token classifications
 const (
-	tokError    tokType = "Error"
-	tokEOF              = "EOF"
-	tokPlus             = "+"
-	tokDash             = "-"
-	tokComment          = "--"
-	tokStar             = "*"
-	tokSlash            = "/"
-	tokQuestion         = "?"
-	tokSemi             = ";"
-	tokComma            = ","
-	tokDot              = "."
-	tokParenL           = "("
-	tokParenR           = ")"
-	tokBraceL           = "{"
-	tokBraceR           = "}"
-	tokBracketL         = "["
-	tokBracketR         = "]"
-	tokColon            = ":"
-	tokAssign           = ":="
-	tokArrow            = "=>"
-	tokLT               = "<"
-	tokGT               = ">"
-	tokLTEquals         = "<="
-	tokGTEquals         = ">="
-	tokFn               = "fn"
-	tokIf               = "if"
-	tokLet              = "let"
-	tokReturn           = "return"
-	tokSelf             = "self"
-	tokUse              = "use"
-	tokPub              = "pub"
-	tokIdent            = "Ident"
-	tokNumber           = "Number"
-	tokString           = "String"
-	tokBoolean          = "Boolean"
+	tokError     tokType = "Error"
+	tokEOF               = "EOF"
+	tokPlus              = "+"
+	tokDash              = "-"
+	tokComment           = "--"
+	tokStar              = "*"
+	tokSlash             = "/"
+	tokPercent           = "%"
+	tokQuestion          = "?"
+	tokSemi              = ";"
+	tokComma             = ","
+	tokDot               = "."
+	tokParenL            = "("
+	tokParenR            = ")"
+	tokBraceL            = "{"
+	tokBraceR            = "}"
+	tokBracketL          = "["
+	tokBracketR          = "]"
+	tokColon             = ":"
+	tokAssign            = ":="
+	tokArrow             = "=>"
+	tokLT                = "<"
+	tokGT                = ">"
+	tokLTEquals          = "<="
+	tokGTEquals          = ">="
+	tokEquals            = "=="
+	tokNotEquals         = "!="
+	tokAnd               = "&&"
+	tokOr                = "||"
+	tokBang              = "!"
+	tokCoalesce          = "??"
+	tokFn                = "fn"
+	tokIf                = "if"
+	tokElse              = "else"
+	tokWhile             = "while"
+	tokBreak             = "break"
+	tokContinue          = "continue"
+	tokLet               = "let"
+	tokConst             = "const"
+	tokTypeDef           = "type"
+	tokEnum              = "enum"
+	tokMatch             = "match"
+	tokAs                = "as"
+	tokIs                = "is"
+	tokTypeof            = "typeof"
+	tokReturn            = "return"
+	tokSelf              = "self"
+	tokUse               = "use"
+	tokPub               = "pub"
+	tokNone              = "none"
+	tokIdent             = "Ident"
+	tokNumber            = "Number"
+	tokFloat             = "Float"
+	tokString            = "String"
+	tokBoolean           = "Boolean"
 )
 
 // token is a basic syntactic unit
@@ -104,6 +124,8 @@ func isOperator(r rune) bool {
 	case '*':
 		fallthrough
 	case '/':
+		fallthrough
+	case '%':
 		return true
 	case '?':
 		return true
@@ -115,6 +137,12 @@ func isOperator(r rune) bool {
 		return true
 	case '>':
 		return true
+	case '!':
+		return true
+	case '&':
+		return true
+	case '|':
+		return true
 	default:
 		return false
 	}
@@ -224,8 +252,17 @@ func eatOperatorToken(scn *scanner) token {
 		return token{tokStar, "*", scn.next().loc}
 	case '/':
 		return token{tokSlash, "/", scn.next().loc}
+	case '%':
+		return token{tokPercent, "%", scn.next().loc}
 	case '?':
-		return token{tokQuestion, "?", scn.next().loc}
+		question := scn.next()
+
+		if scn.peek().char == '?' {
+			scn.next()
+			return token{tokCoalesce, "??", question.loc}
+		}
+
+		return token{tokQuestion, "?", question.loc}
 	case ':':
 		colon := scn.next()
 		tok := token{tokColon, ":", colon.loc}
@@ -242,9 +279,39 @@ func eatOperatorToken(scn *scanner) token {
 		if scn.peek().char == '>' {
 			scn.next()
 			return token{tokArrow, "=>", equals.loc}
+		} else if scn.peek().char == '=' {
+			scn.next()
+			return token{tokEquals, "==", equals.loc}
 		}
 
 		return token{tokError, "expected operator", equals.loc}
+	case '!':
+		bang := scn.next()
+
+		if scn.peek().char == '=' {
+			scn.next()
+			return token{tokNotEquals, "!=", bang.loc}
+		}
+
+		return token{tokBang, "!", bang.loc}
+	case '&':
+		amp := scn.next()
+
+		if scn.peek().char == '&' {
+			scn.next()
+			return token{tokAnd, "&&", amp.loc}
+		}
+
+		return token{tokError, "expected operator", amp.loc}
+	case '|':
+		pipe := scn.next()
+
+		if scn.peek().char == '|' {
+			scn.next()
+			return token{tokOr, "||", pipe.loc}
+		}
+
+		return token{tokError, "expected operator", pipe.loc}
 	case '<':
 		lt := scn.next()
 
@@ -342,8 +409,30 @@ func eatWordToken(scn *scanner) token {
 		return token{tokFn, "fn", loc}
 	case "if":
 		return token{tokIf, "if", loc}
+	case "else":
+		return token{tokElse, "else", loc}
+	case "while":
+		return token{tokWhile, "while", loc}
+	case "break":
+		return token{tokBreak, "break", loc}
+	case "continue":
+		return token{tokContinue, "continue", loc}
 	case "let":
 		return token{tokLet, "let", loc}
+	case "const":
+		return token{tokConst, "const", loc}
+	case "type":
+		return token{tokTypeDef, "type", loc}
+	case "enum":
+		return token{tokEnum, "enum", loc}
+	case "match":
+		return token{tokMatch, "match", loc}
+	case "as":
+		return token{tokAs, "as", loc}
+	case "is":
+		return token{tokIs, "is", loc}
+	case "typeof":
+		return token{tokTypeof, "typeof", loc}
 	case "return":
 		return token{tokReturn, "return", loc}
 	case "self":
@@ -352,6 +441,8 @@ func eatWordToken(scn *scanner) token {
 		return token{tokUse, "use", loc}
 	case "pub":
 		return token{tokPub, "pub", loc}
+	case "none":
+		return token{tokNone, "none", loc}
 	case "true":
 		return token{tokBoolean, "true", loc}
 	case "false":
@@ -373,7 +464,39 @@ func eatNumberToken(scn *scanner) token {
 		lexeme += string(scn.next().char)
 	}
 
-	return token{tokNumber, lexeme, loc}
+	var typ tokType = tokNumber
+
+	if scn.peek().char == '.' {
+		typ = tokFloat
+		lexeme += string(scn.next().char)
+
+		if isDigit(scn.peek().char) == false {
+			return token{tokError, "expected digit after decimal point", scn.peek().loc}
+		}
+
+		for isDigit(scn.peek().char) {
+			lexeme += string(scn.next().char)
+		}
+	}
+
+	if scn.peek().char == 'e' || scn.peek().char == 'E' {
+		typ = tokFloat
+		lexeme += string(scn.next().char)
+
+		if scn.peek().char == '+' || scn.peek().char == '-' {
+			lexeme += string(scn.next().char)
+		}
+
+		if isDigit(scn.peek().char) == false {
+			return token{tokError, "expected digit in exponent", scn.peek().loc}
+		}
+
+		for isDigit(scn.peek().char) {
+			lexeme += string(scn.next().char)
+		}
+	}
+
+	return token{typ, lexeme, loc}
 }
 
 func eatStringToken(scn *scanner) token {
@@ -39,6 +39,7 @@ func TestIsOperator(t *testing.T) {
 	expectRunePredicateBool(t, isOperator, '=', true)
 	expectRunePredicateBool(t, isOperator, '<', true)
 	expectRunePredicateBool(t, isOperator, '>', true)
+	expectRunePredicateBool(t, isOperator, '!', true)
 	expectRunePredicateBool(t, isOperator, '#', false)
 }
 
@@ -94,6 +95,10 @@ func TestEatToken(t *testing.T) {
 	expectLexer(t, eatToken, "foo", token{tokIdent, "foo", Loc{1, 1}})
 	expectLexer(t, eatToken, "fn", token{tokFn, "fn", Loc{1, 1}})
 	expectLexer(t, eatToken, "if", token{tokIf, "if", Loc{1, 1}})
+	expectLexer(t, eatToken, "else", token{tokElse, "else", Loc{1, 1}})
+	expectLexer(t, eatToken, "while", token{tokWhile, "while", Loc{1, 1}})
+	expectLexer(t, eatToken, "break", token{tokBreak, "break", Loc{1, 1}})
+	expectLexer(t, eatToken, "continue", token{tokContinue, "continue", Loc{1, 1}})
 	expectLexer(t, eatToken, "let", token{tokLet, "let", Loc{1, 1}})
 	expectLexer(t, eatToken, "return", token{tokReturn, "return", Loc{1, 1}})
 	expectLexer(t, eatToken, "self", token{tokSelf, "self", Loc{1, 1}})
@@ -112,7 +117,9 @@ func TestEatOperatorToken(t *testing.T) {
 	expectLexer(t, eatOperatorToken, "-", token{tokDash, "-", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, "*", token{tokStar, "*", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, "/", token{tokSlash, "/", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "%", token{tokPercent, "%", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, "?", token{tokQuestion, "?", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "??", token{tokCoalesce, "??", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, ":", token{tokColon, ":", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, ":=", token{tokAssign, ":=", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, "=>", token{tokArrow, "=>", Loc{1, 1}})
@@ -120,9 +127,16 @@ func TestEatOperatorToken(t *testing.T) {
 	expectLexer(t, eatOperatorToken, "<=", token{tokLTEquals, "<=", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, ">", token{tokGT, ">", Loc{1, 1}})
 	expectLexer(t, eatOperatorToken, ">=", token{tokGTEquals, ">=", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "==", token{tokEquals, "==", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "!=", token{tokNotEquals, "!=", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "!", token{tokBang, "!", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "&&", token{tokAnd, "&&", Loc{1, 1}})
+	expectLexer(t, eatOperatorToken, "||", token{tokOr, "||", Loc{1, 1}})
 
 	expectLexerError(t, eatOperatorToken, "@", "(1:1) expected operator")
 	expectLexerError(t, eatOperatorToken, "=", "(1:1) expected operator")
+	expectLexerError(t, eatOperatorToken, "&", "(1:1) expected operator")
+	expectLexerError(t, eatOperatorToken, "|", "(1:1) expected operator")
 }
 
 func TestEatSemicolonToken(t *testing.T) {
@@ -168,11 +182,23 @@ func TestEatWordToken(t *testing.T) {
 	expectLexer(t, eatWordToken, "foo", token{tokIdent, "foo", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "fn", token{tokFn, "fn", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "if", token{tokIf, "if", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "else", token{tokElse, "else", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "while", token{tokWhile, "while", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "break", token{tokBreak, "break", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "continue", token{tokContinue, "continue", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "let", token{tokLet, "let", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "type", token{tokTypeDef, "type", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "const", token{tokConst, "const", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "enum", token{tokEnum, "enum", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "match", token{tokMatch, "match", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "as", token{tokAs, "as", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "is", token{tokIs, "is", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "typeof", token{tokTypeof, "typeof", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "return", token{tokReturn, "return", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "self", token{tokSelf, "self", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "use", token{tokUse, "use", Loc{1, 1}})
 	expectLexer(t, eatWordToken, "pub", token{tokPub, "pub", Loc{1, 1}})
+	expectLexer(t, eatWordToken, "none", token{tokNone, "none", Loc{1, 1}})
 
 	expectLexerError(t, eatWordToken, "123", "(1:1) expected word")
 	expectLexerError(t, eatWordToken, "", "(1:0) expected word")
@@ -180,9 +206,18 @@ func TestEatWordToken(t *testing.T) {
 
 func TestEatNumberToken(t *testing.T) {
 	expectLexer(t, eatNumberToken, "123", token{tokNumber, "123", Loc{1, 1}})
+	expectLexer(t, eatNumberToken, "1.5", token{tokFloat, "1.5", Loc{1, 1}})
+	expectLexer(t, eatNumberToken, "0.25", token{tokFloat, "0.25", Loc{1, 1}})
+	expectLexer(t, eatNumberToken, "1e10", token{tokFloat, "1e10", Loc{1, 1}})
+	expectLexer(t, eatNumberToken, "2.5E-3", token{tokFloat, "2.5E-3", Loc{1, 1}})
+	expectLexer(t, eatNumberToken, "3e+2", token{tokFloat, "3e+2", Loc{1, 1}})
 
 	expectLexerError(t, eatNumberToken, "foo", "(1:1) expected number")
 	expectLexerError(t, eatNumberToken, "", "(1:0) expected number")
+	expectLexerError(t, eatNumberToken, "1.", "(1:2) expected digit after decimal point")
+	expectLexerError(t, eatNumberToken, "1.x", "(1:3) expected digit after decimal point")
+	expectLexerError(t, eatNumberToken, "1e", "(1:2) expected digit in exponent")
+	expectLexerError(t, eatNumberToken, "1e+", "(1:3) expected digit in exponent")
 }
 
 func TestEatStringToken(t *testing.T) {
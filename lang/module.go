@@ -3,6 +3,7 @@ package lang
 import (
 	"fmt"
 	"plaid/lang/types"
+	"sort"
 	"strings"
 )
 
@@ -10,6 +11,7 @@ type Module interface {
 	fmt.Stringer
 	Identifier() string
 	Exports() types.Struct
+	ExportedTypes() map[string]types.Type
 	Dependencies() []Module
 	IsNative() bool
 	link(string, string, Module)
@@ -47,6 +49,10 @@ func (m *ModuleNative) Exports() types.Struct {
 	return m.library.toType()
 }
 
+func (m *ModuleNative) ExportedTypes() map[string]types.Type {
+	return nil
+}
+
 func (m *ModuleNative) Dependencies() []Module {
 	return nil
 }
@@ -62,11 +68,12 @@ func (m *ModuleNative) export(filter ...string) Object {
 }
 
 type ModuleVirtual struct {
-	path         string
-	exports      types.Struct
-	structure    *AST
-	scope        *Scope
-	dependencies []struct {
+	path          string
+	exports       types.Struct
+	exportedTypes map[string]types.Type
+	structure     *AST
+	scope         *Scope
+	dependencies  []struct {
 		alias    string
 		relative string
 		module   Module
@@ -89,6 +96,20 @@ func (m *ModuleVirtual) String() string {
 		}
 	}
 
+	if len(m.exportedTypes) > 0 {
+		var names []string
+		for name := range m.exportedTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		lines = append(lines, "types:")
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("  - name: \"%s\"", name))
+			lines = append(lines, fmt.Sprintf("    type: %s", m.exportedTypes[name]))
+		}
+	}
+
 	if len(m.dependencies) > 0 {
 		lines = append(lines, "dependencies:")
 		for _, dep := range m.dependencies {
@@ -116,6 +137,17 @@ func (m *ModuleVirtual) AddExport(name string, typ types.Type) {
 	m.exports = types.Struct{append(m.exports.Fields, field)}
 }
 
+func (m *ModuleVirtual) ExportedTypes() map[string]types.Type {
+	return m.exportedTypes
+}
+
+func (m *ModuleVirtual) AddExportedType(name string, typ types.Type) {
+	if m.exportedTypes == nil {
+		m.exportedTypes = make(map[string]types.Type)
+	}
+	m.exportedTypes[name] = typ
+}
+
 func (m *ModuleVirtual) Dependencies() []Module {
 	var deps []Module
 	for _, dep := range m.dependencies {
@@ -150,7 +182,9 @@ func (m *ModuleVirtual) export(filter ...string) Object {
 	// Export everything as a single ObjectStruct.
 	fields := make(map[string]Object)
 	for _, field := range m.exports.Fields {
-		fields[field.Name] = m.environment.load(field.Name)
+		if index, ok := slotIndex(m.scope, field.Name); ok {
+			fields[field.Name], _ = m.environment.load(0, index)
+		}
 	}
 	return &ObjectStruct{fields}
 }
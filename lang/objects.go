@@ -3,11 +3,16 @@ package lang
 import (
 	"fmt"
 	"plaid/lang/types"
+	"strconv"
+	"strings"
 )
 
+// Object describes all runtime values. Equals compares two objects by value
+// except for functions and closures which are only equal to themselves
 type Object interface {
 	fmt.Stringer
 	Value() interface{}
+	Equals(other Object) bool
 	isObject()
 }
 
@@ -17,6 +22,15 @@ func (o ObjectNone) Value() interface{} { return nil }
 func (o ObjectNone) String() string     { return "<none>" }
 func (o ObjectNone) isObject()          {}
 
+func (o ObjectNone) Equals(other Object) bool {
+	switch other.(type) {
+	case ObjectNone, *ObjectNone:
+		return true
+	default:
+		return false
+	}
+}
+
 type ObjectInt struct {
 	val int64
 }
@@ -25,6 +39,33 @@ func (o ObjectInt) Value() interface{} { return o.val }
 func (o ObjectInt) String() string     { return fmt.Sprintf("%d", o.val) }
 func (o ObjectInt) isObject()          {}
 
+func (o ObjectInt) Equals(other Object) bool {
+	val, ok := other.Value().(int64)
+	return ok && val == o.val
+}
+
+type ObjectFloat struct {
+	val float64
+}
+
+func (o ObjectFloat) Value() interface{} { return o.val }
+func (o ObjectFloat) String() string     { return formatFloat(o.val) }
+func (o ObjectFloat) isObject()          {}
+
+func (o ObjectFloat) Equals(other Object) bool {
+	val, ok := other.Value().(float64)
+	return ok && val == o.val
+}
+
+// formatFloat prints a float so that it can't be confused with an integer
+func formatFloat(val float64) string {
+	str := strconv.FormatFloat(val, 'g', -1, 64)
+	if strings.ContainsAny(str, ".eEnN") {
+		return str
+	}
+	return str + ".0"
+}
+
 type ObjectStr struct {
 	val string
 }
@@ -33,6 +74,21 @@ func (o ObjectStr) Value() interface{} { return o.val }
 func (o ObjectStr) String() string     { return fmt.Sprintf("\"%s\"", o.val) }
 func (o ObjectStr) isObject()          {}
 
+// Index returns the character at the given position as a string or
+// ObjectNone if the position is out of range
+func (o ObjectStr) Index(index int64) Object {
+	chars := []rune(o.val)
+	if index < 0 || index >= int64(len(chars)) {
+		return &ObjectNone{}
+	}
+	return &ObjectStr{string(chars[index])}
+}
+
+func (o ObjectStr) Equals(other Object) bool {
+	val, ok := other.Value().(string)
+	return ok && val == o.val
+}
+
 type ObjectBool struct {
 	val bool
 }
@@ -41,34 +97,143 @@ func (o ObjectBool) Value() interface{} { return o.val }
 func (o ObjectBool) String() string     { return fmt.Sprintf("%t", o.val) }
 func (o ObjectBool) isObject()          {}
 
+func (o ObjectBool) Equals(other Object) bool {
+	val, ok := other.Value().(bool)
+	return ok && val == o.val
+}
+
+// MakeInt wraps an integer as a runtime value
+func MakeInt(val int64) *ObjectInt { return &ObjectInt{val} }
+
+// MakeFloat wraps a floating-point number as a runtime value
+func MakeFloat(val float64) *ObjectFloat { return &ObjectFloat{val} }
+
+type ObjectList struct {
+	elements []Object
+}
+
+func (o ObjectList) Value() interface{} { return o.elements }
+func (o ObjectList) isObject()          {}
+
+func (o ObjectList) String() string {
+	var elems []string
+	for _, elem := range o.elements {
+		elems = append(elems, elem.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
+func (o ObjectList) Equals(other Object) bool {
+	var elements []Object
+	switch other := other.(type) {
+	case ObjectList:
+		elements = other.elements
+	case *ObjectList:
+		elements = other.elements
+	default:
+		return false
+	}
+
+	if len(o.elements) != len(elements) {
+		return false
+	}
+
+	for i, elem := range o.elements {
+		if elem.Equals(elements[i]) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Index returns the element at the given position or ObjectNone if the
+// position is out of range
+func (o ObjectList) Index(index int64) Object {
+	if index < 0 || index >= int64(len(o.elements)) {
+		return &ObjectNone{}
+	}
+	return o.elements[index]
+}
+
 type ObjectBuiltin struct {
 	typ types.Type
 	val func(args []Object) (Object, error)
 }
 
-func (o ObjectBuiltin) Type() types.Type   { return o.typ }
-func (o ObjectBuiltin) Value() interface{} { return o.val }
-func (o ObjectBuiltin) String() string     { return "<builtin>" }
-func (o ObjectBuiltin) isObject()          {}
+func (o ObjectBuiltin) Type() types.Type          { return o.typ }
+func (o ObjectBuiltin) Value() interface{}        { return o.val }
+func (o ObjectBuiltin) String() string            { return "<builtin>" }
+func (o ObjectBuiltin) isObject()                 {}
+func (o *ObjectBuiltin) Equals(other Object) bool { return o == other }
 
 type ObjectFunction struct {
+	name     string
 	params   []string
 	bytecode Bytecode
 }
 
-func (o ObjectFunction) Value() interface{} { return o.bytecode }
-func (o ObjectFunction) String() string     { return "<function>" }
-func (o ObjectFunction) isObject()          {}
+func (o ObjectFunction) Value() interface{}        { return o.bytecode }
+func (o ObjectFunction) String() string            { return "<function>" }
+func (o ObjectFunction) isObject()                 {}
+func (o *ObjectFunction) Equals(other Object) bool { return o == other }
 
 type ObjectClosure struct {
 	context  *Environment
+	name     string
 	params   []string
 	bytecode Bytecode
 }
 
-func (o ObjectClosure) Value() interface{} { return o.bytecode }
-func (o ObjectClosure) String() string     { return "<closure>" }
-func (o ObjectClosure) isObject()          {}
+func (o ObjectClosure) Value() interface{}        { return o.bytecode }
+func (o ObjectClosure) String() string            { return "<closure>" }
+func (o ObjectClosure) isObject()                 {}
+func (o *ObjectClosure) Equals(other Object) bool { return o == other }
+
+type ObjectVariant struct {
+	enum    string
+	tag     string
+	payload []Object
+}
+
+func (o ObjectVariant) Value() interface{} { return o.payload }
+func (o ObjectVariant) isObject()          {}
+
+func (o ObjectVariant) String() string {
+	if len(o.payload) == 0 {
+		return o.tag
+	}
+
+	var values []string
+	for _, obj := range o.payload {
+		values = append(values, obj.String())
+	}
+	return fmt.Sprintf("%s(%s)", o.tag, strings.Join(values, ", "))
+}
+
+func (o ObjectVariant) Equals(other Object) bool {
+	var other2 ObjectVariant
+	switch other := other.(type) {
+	case ObjectVariant:
+		other2 = other
+	case *ObjectVariant:
+		other2 = *other
+	default:
+		return false
+	}
+
+	if o.enum != other2.enum || o.tag != other2.tag || len(o.payload) != len(other2.payload) {
+		return false
+	}
+
+	for i, obj := range o.payload {
+		if obj.Equals(other2.payload[i]) == false {
+			return false
+		}
+	}
+
+	return true
+}
 
 type ObjectStruct struct {
 	fields map[string]Object
@@ -78,3 +243,100 @@ func (o ObjectStruct) Value() interface{}        { return nil }
 func (o ObjectStruct) String() string            { return "<struct>" }
 func (o ObjectStruct) isObject()                 {}
 func (o ObjectStruct) Member(name string) Object { return o.fields[name] }
+
+func (o ObjectStruct) Equals(other Object) bool {
+	var fields map[string]Object
+	switch other := other.(type) {
+	case ObjectStruct:
+		fields = other.fields
+	case *ObjectStruct:
+		fields = other.fields
+	default:
+		return false
+	}
+
+	if len(o.fields) != len(fields) {
+		return false
+	}
+
+	for name, obj := range o.fields {
+		if obj2, ok := fields[name]; !ok || obj.Equals(obj2) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// typeName returns the name of the runtime type of an object. Enum values are
+// named after the enum that declared them
+func typeName(obj Object) string {
+	switch obj := obj.(type) {
+	case ObjectNone, *ObjectNone:
+		return "None"
+	case ObjectInt, *ObjectInt:
+		return "Int"
+	case ObjectFloat, *ObjectFloat:
+		return "Float"
+	case ObjectStr, *ObjectStr:
+		return "Str"
+	case ObjectBool, *ObjectBool:
+		return "Bool"
+	case ObjectList, *ObjectList:
+		return "List"
+	case ObjectStruct, *ObjectStruct:
+		return "Struct"
+	case *ObjectVariant:
+		return obj.enum
+	case ObjectVariant:
+		return obj.enum
+	default:
+		return "Function"
+	}
+}
+
+// hasType returns true if an object is a valid value of the given type. Lists
+// and structs are checked element by element
+func hasType(obj Object, typ types.Type) bool {
+	switch typ := typ.(type) {
+	case types.Any:
+		return true
+	case types.None:
+		return typeName(obj) == "None"
+	case types.Optional:
+		return typeName(obj) == "None" || hasType(obj, typ.Child)
+	case types.Ident:
+		return typeName(obj) == typ.Name
+	case types.List:
+		if list, ok := obj.(*ObjectList); ok {
+			for _, elem := range list.elements {
+				if hasType(elem, typ.Child) == false {
+					return false
+				}
+			}
+			return true
+		}
+	case types.Struct:
+		if strct, ok := obj.(*ObjectStruct); ok && len(strct.fields) == len(typ.Fields) {
+			for _, field := range typ.Fields {
+				if member, ok := strct.fields[field.Name]; !ok || hasType(member, field.Type) == false {
+					return false
+				}
+			}
+			return true
+		}
+	case types.Union:
+		if variant, ok := obj.(*ObjectVariant); ok && variant.enum == typ.Name {
+			if payload, ok := typ.Variant(variant.tag); ok && len(payload.Payload) == len(variant.payload) {
+				for i, obj := range variant.payload {
+					if hasType(obj, payload.Payload[i]) == false {
+						return false
+					}
+				}
+				return true
+			}
+		}
+	}
+
+	return false
+}
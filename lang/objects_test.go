@@ -1,6 +1,9 @@
 package lang
 
-import "testing"
+import (
+	"plaid/lang/types"
+	"testing"
+)
 
 func TestObjectNone(t *testing.T) {
 	obj := &ObjectNone{}
@@ -14,6 +17,13 @@ func TestObjectInt(t *testing.T) {
 	expectString(t, obj.String(), "123")
 }
 
+func TestObjectFloat(t *testing.T) {
+	obj := &ObjectFloat{val: 1.5}
+	obj.isObject()
+	expectString(t, obj.String(), "1.5")
+	expectString(t, (&ObjectFloat{val: 3}).String(), "3.0")
+}
+
 func TestObjectStr(t *testing.T) {
 	obj := &ObjectStr{val: "abc"}
 	obj.isObject()
@@ -26,6 +36,29 @@ func TestObjectBool(t *testing.T) {
 	expectString(t, obj.String(), "true")
 }
 
+func TestObjectList(t *testing.T) {
+	obj := &ObjectList{[]Object{&ObjectInt{1}, &ObjectStr{"a"}}}
+	obj.isObject()
+	expectString(t, obj.String(), `[1, "a"]`)
+	expectString(t, (&ObjectList{}).String(), "[]")
+	expectString(t, obj.Index(0).String(), "1")
+	expectString(t, obj.Index(1).String(), `"a"`)
+	expectString(t, obj.Index(2).String(), "<none>")
+	expectString(t, obj.Index(-1).String(), "<none>")
+}
+
+func TestObjectVariant(t *testing.T) {
+	obj := &ObjectVariant{"Shape", "Rect", []Object{&ObjectInt{1}, &ObjectStr{"a"}}}
+	obj.isObject()
+	expectString(t, obj.String(), `Rect(1, "a")`)
+	expectString(t, (&ObjectVariant{"Shape", "Empty", nil}).String(), "Empty")
+	expectBool(t, obj.Equals(&ObjectVariant{"Shape", "Rect", []Object{&ObjectInt{1}, &ObjectStr{"a"}}}), true)
+	expectBool(t, obj.Equals(&ObjectVariant{"Shape", "Rect", []Object{&ObjectInt{2}, &ObjectStr{"a"}}}), false)
+	expectBool(t, obj.Equals(&ObjectVariant{"Shape", "Rect", []Object{&ObjectInt{1}}}), false)
+	expectBool(t, obj.Equals(&ObjectVariant{"Shape", "Box", []Object{&ObjectInt{1}, &ObjectStr{"a"}}}), false)
+	expectBool(t, obj.Equals(&ObjectInt{1}), false)
+}
+
 func TestObjectBuiltin(t *testing.T) {
 	obj := &ObjectBuiltin{}
 	obj.isObject()
@@ -43,3 +76,87 @@ func TestObjectClosure(t *testing.T) {
 	obj.isObject()
 	expectString(t, obj.String(), "<closure>")
 }
+
+func TestTypeName(t *testing.T) {
+	expectString(t, typeName(&ObjectNone{}), "None")
+	expectString(t, typeName(&ObjectInt{1}), "Int")
+	expectString(t, typeName(&ObjectFloat{1}), "Float")
+	expectString(t, typeName(&ObjectStr{"a"}), "Str")
+	expectString(t, typeName(&ObjectBool{true}), "Bool")
+	expectString(t, typeName(&ObjectList{}), "List")
+	expectString(t, typeName(&ObjectStruct{}), "Struct")
+	expectString(t, typeName(&ObjectVariant{"Shape", "Empty", nil}), "Shape")
+	expectString(t, typeName(&ObjectClosure{}), "Function")
+	expectString(t, typeName(&ObjectBuiltin{}), "Function")
+}
+
+func TestHasType(t *testing.T) {
+	expectBool(t, hasType(&ObjectInt{1}, types.BuiltinInt), true)
+	expectBool(t, hasType(&ObjectInt{1}, types.BuiltinStr), false)
+	expectBool(t, hasType(&ObjectInt{1}, types.Any{}), true)
+	expectBool(t, hasType(&ObjectNone{}, types.None{}), true)
+	expectBool(t, hasType(&ObjectNone{}, types.Optional{Child: types.BuiltinInt}), true)
+	expectBool(t, hasType(&ObjectInt{1}, types.Optional{Child: types.BuiltinInt}), true)
+	expectBool(t, hasType(&ObjectNone{}, types.BuiltinInt), false)
+
+	list := &ObjectList{[]Object{&ObjectInt{1}, &ObjectNone{}}}
+	expectBool(t, hasType(list, types.List{Child: types.Optional{Child: types.BuiltinInt}}), true)
+	expectBool(t, hasType(list, types.List{Child: types.BuiltinInt}), false)
+	expectBool(t, hasType(&ObjectList{}, types.List{Child: types.BuiltinStr}), true)
+
+	point := types.Struct{Fields: []struct {
+		Name string
+		Type types.Type
+	}{{"x", types.BuiltinInt}}}
+	expectBool(t, hasType(&ObjectStruct{map[string]Object{"x": &ObjectInt{1}}}, point), true)
+	expectBool(t, hasType(&ObjectStruct{map[string]Object{"x": &ObjectStr{"a"}}}, point), false)
+	expectBool(t, hasType(&ObjectStruct{map[string]Object{"y": &ObjectInt{1}}}, point), false)
+
+	shape := types.Union{Name: "Shape", Variants: []types.Variant{
+		{Name: "Empty"},
+		{Name: "Circle", Payload: []types.Type{types.BuiltinFloat}},
+	}}
+	expectBool(t, hasType(&ObjectVariant{"Shape", "Empty", nil}, shape), true)
+	expectBool(t, hasType(&ObjectVariant{"Shape", "Circle", []Object{&ObjectFloat{1}}}, shape), true)
+	expectBool(t, hasType(&ObjectVariant{"Shape", "Circle", []Object{&ObjectInt{1}}}, shape), false)
+	expectBool(t, hasType(&ObjectVariant{"Shape", "Square", nil}, shape), false)
+	expectBool(t, hasType(&ObjectVariant{"Color", "Empty", nil}, shape), false)
+}
+
+func TestObjectEquals(t *testing.T) {
+	expectBool(t, (&ObjectNone{}).Equals(ObjectNone{}), true)
+	expectBool(t, (&ObjectNone{}).Equals(&ObjectInt{0}), false)
+	expectBool(t, (&ObjectInt{1}).Equals(&ObjectInt{1}), true)
+	expectBool(t, (&ObjectInt{1}).Equals(ObjectInt{1}), true)
+	expectBool(t, (&ObjectInt{1}).Equals(&ObjectInt{2}), false)
+	expectBool(t, (&ObjectInt{1}).Equals(&ObjectStr{"1"}), false)
+	expectBool(t, (&ObjectInt{1}).Equals(&ObjectFloat{1}), false)
+	expectBool(t, (&ObjectFloat{0.5}).Equals(&ObjectFloat{0.5}), true)
+	expectBool(t, (&ObjectFloat{0.5}).Equals(&ObjectFloat{1.5}), false)
+	expectBool(t, (&ObjectStr{"a"}).Equals(&ObjectStr{"a"}), true)
+	expectBool(t, (&ObjectStr{"a"}).Equals(&ObjectStr{"b"}), false)
+	expectBool(t, (&ObjectBool{true}).Equals(&ObjectBool{true}), true)
+	expectBool(t, (&ObjectBool{true}).Equals(&ObjectBool{false}), false)
+	expectBool(t, (&ObjectBool{false}).Equals(&ObjectNone{}), false)
+
+	list1 := &ObjectList{[]Object{&ObjectInt{1}, &ObjectInt{2}}}
+	list2 := &ObjectList{[]Object{&ObjectInt{1}, &ObjectInt{2}}}
+	list3 := &ObjectList{[]Object{&ObjectInt{1}}}
+	expectBool(t, list1.Equals(list2), true)
+	expectBool(t, list1.Equals(list3), false)
+	expectBool(t, list1.Equals(&ObjectInt{1}), false)
+
+	clo1 := &ObjectClosure{}
+	clo2 := &ObjectClosure{}
+	expectBool(t, clo1.Equals(clo1), true)
+	expectBool(t, clo1.Equals(clo2), false)
+
+	str1 := &ObjectStruct{map[string]Object{"x": &ObjectInt{1}, "y": &ObjectStr{"a"}}}
+	str2 := &ObjectStruct{map[string]Object{"x": &ObjectInt{1}, "y": &ObjectStr{"a"}}}
+	str3 := &ObjectStruct{map[string]Object{"x": &ObjectInt{1}, "z": &ObjectStr{"a"}}}
+	str4 := &ObjectStruct{map[string]Object{"x": &ObjectInt{1}}}
+	expectBool(t, str1.Equals(str2), true)
+	expectBool(t, str1.Equals(str3), false)
+	expectBool(t, str1.Equals(str4), false)
+	expectBool(t, str1.Equals(&ObjectNone{}), false)
+}
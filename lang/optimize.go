@@ -0,0 +1,313 @@
+package lang
+
+// OptLevel controls which optimizations Compile applies to the bytecode
+type OptLevel int
+
+const (
+	// OptNone emits the bytecode exactly as the syntax tree describes it
+	OptNone OptLevel = iota
+
+	// OptBasic folds constant expressions, removes unreachable code and
+	// cleans up wasteful instruction sequences
+	OptBasic
+)
+
+// optimize rewrites the bytecode until none of the optimizations apply
+// anymore. The bodies of any functions pushed by the bytecode are optimized
+// too. Positions follow the instructions they belong to and folded
+// instructions take the position of the instruction that consumed them
+func optimize(blob Bytecode) Bytecode {
+	blob = optimizeFunctions(blob)
+	for {
+		var changed bool
+		if blob, changed = optimizePass(blob); changed == false {
+			return blob
+		}
+	}
+}
+
+func optimizeFunctions(blob Bytecode) Bytecode {
+	out := Bytecode{
+		Instructions: append([]Instr{}, blob.Instructions...),
+		Positions:    append([]Position{}, blob.Positions...),
+	}
+
+	for addr, instr := range out.Instructions {
+		if push, ok := instr.(InstrPush); ok {
+			if fn, ok := push.Val.(*ObjectFunction); ok {
+				out.Instructions[addr] = InstrPush{&ObjectFunction{
+					name:     fn.name,
+					params:   fn.params,
+					bytecode: optimize(fn.bytecode),
+				}}
+			}
+		}
+	}
+
+	return out
+}
+
+// optimizePass removes unreachable instructions and applies the peephole
+// rules once, then relocates every jump. It returns false if nothing changed
+func optimizePass(blob Bytecode) (Bytecode, bool) {
+	instrs := append([]Instr{}, blob.Instructions...)
+	removed := make([]bool, len(instrs))
+	targets := jumpTargets(instrs)
+	changed := false
+
+	for addr, ok := range reachableInstrs(instrs) {
+		if ok == false {
+			removed[addr] = true
+			changed = true
+		}
+	}
+
+	for addr := 0; addr < len(instrs); addr++ {
+		// Rules only apply to instructions that are always executed in order
+		end := addr + 1
+		for end < len(instrs) && end-addr < 3 && removed[end] == false && targets[Address(end)] == false {
+			end++
+		}
+
+		if removed[addr] {
+			continue
+		}
+
+		n, out, ok := peephole(Address(addr), instrs[addr:end])
+		if ok == false {
+			continue
+		}
+
+		// Replacements take the place of the last instructions they replace
+		for i := 0; i < n; i++ {
+			removed[addr+i] = i < n-len(out)
+		}
+		copy(instrs[addr+n-len(out):], out)
+		addr += n - 1
+		changed = true
+	}
+
+	if changed == false {
+		return blob, false
+	}
+
+	// Every removed instruction maps to the address of the next instruction
+	// that's kept, jumps to removed instructions fall through to it
+	relocated := make([]Address, len(instrs)+1)
+	out := Bytecode{}
+	for addr, instr := range instrs {
+		relocated[addr] = out.nextInstrPtr()
+		if removed[addr] == false {
+			out.Instructions = append(out.Instructions, instr)
+			pos, _ := blob.Position(Address(addr))
+			out.Positions = append(out.Positions, pos)
+		}
+	}
+	relocated[len(instrs)] = out.nextInstrPtr()
+
+	for addr, instr := range out.Instructions {
+		if target, ok := jumpTarget(instr); ok && int(target) < len(relocated) {
+			out.Instructions[addr] = retarget(instr, relocated[target])
+		}
+	}
+
+	return out, true
+}
+
+// peephole matches a rule against the start of a window of instructions that
+// are executed in order. It returns how many instructions the rule replaces
+// and what to replace them with
+func peephole(addr Address, window []Instr) (int, []Instr, bool) {
+	switch first := window[0].(type) {
+	case InstrNOP:
+		return 1, nil, true
+	case InstrJump:
+		if first.addr == addr+1 {
+			return 1, nil, true
+		}
+	}
+
+	if len(window) >= 2 {
+		if _, ok := window[1].(InstrPop); ok {
+			switch window[0].(type) {
+			case InstrPush, InstrLoad, InstrLoadSelf, InstrCopy:
+				return 2, nil, true
+			}
+		}
+	}
+
+	if len(window) >= 3 {
+		_, isCopy := window[0].(InstrCopy)
+		store, isStore := window[1].(InstrStore)
+		_, isPop := window[2].(InstrPop)
+		if isCopy && isStore && isPop {
+			return 3, []Instr{store}, true
+		}
+	}
+
+	push, ok := window[0].(InstrPush)
+	if ok == false || isConstant(push.Val) == false {
+		return 0, nil, false
+	}
+
+	if len(window) >= 2 {
+		if obj, ok := foldUnary(window[1], push.Val); ok {
+			return 2, []Instr{InstrPush{obj}}, true
+		}
+
+		if cond, ok := push.Val.(*ObjectBool); ok {
+			if jump, taken, ok := foldJump(window[1], cond.val); ok && taken {
+				return 2, []Instr{jump}, true
+			} else if ok {
+				return 2, nil, true
+			}
+		}
+	}
+
+	if len(window) >= 3 {
+		if right, ok := window[1].(InstrPush); ok && isConstant(right.Val) {
+			if obj, ok := foldBinary(window[2], push.Val, right.Val); ok {
+				return 3, []Instr{InstrPush{obj}}, true
+			}
+		}
+
+		// Short-circuiting operators copy the left operand before jumping
+		_, isCopy := window[1].(InstrCopy)
+		if cond, ok := push.Val.(*ObjectBool); ok && isCopy {
+			if jump, taken, ok := foldJump(window[2], cond.val); ok && taken {
+				return 3, []Instr{push, jump}, true
+			} else if ok {
+				return 3, []Instr{push}, true
+			}
+		}
+	}
+
+	return 0, nil, false
+}
+
+// isConstant returns true if the object is a plain value that can safely be
+// shared between instructions
+func isConstant(obj Object) bool {
+	switch obj.(type) {
+	case *ObjectNone, *ObjectInt, *ObjectFloat, *ObjectStr, *ObjectBool:
+		return true
+	default:
+		return false
+	}
+}
+
+func foldUnary(instr Instr, a Object) (Object, bool) {
+	switch instr.(type) {
+	case InstrNeg:
+		switch a := a.(type) {
+		case *ObjectInt:
+			return &ObjectInt{-a.val}, true
+		case *ObjectFloat:
+			return &ObjectFloat{-a.val}, true
+		}
+	case InstrNot:
+		if a, ok := a.(*ObjectBool); ok {
+			return &ObjectBool{a.val == false}, true
+		}
+	}
+	return nil, false
+}
+
+// foldBinary evaluates a binary instruction with constant operands. Anything
+// that would fail at runtime is left for the VM to report
+func foldBinary(instr Instr, a Object, b Object) (Object, bool) {
+	var obj Object
+	var err error
+	switch instr.(type) {
+	case InstrAdd, InstrSub, InstrMul, InstrDiv, InstrRem:
+		obj, err = runArithmetic(instr, a, b)
+	case InstrLT, InstrLTEquals, InstrGT, InstrGTEquals:
+		obj, err = runComparison(instr, a, b)
+	case InstrEquals:
+		obj = &ObjectBool{a.Equals(b)}
+	case InstrNotEquals:
+		obj = &ObjectBool{a.Equals(b) == false}
+	default:
+		return nil, false
+	}
+	return obj, err == nil
+}
+
+// foldJump decides a conditional jump with a known condition. It returns the
+// unconditional jump to use if the jump is taken
+func foldJump(instr Instr, cond bool) (jump Instr, taken bool, ok bool) {
+	switch instr := instr.(type) {
+	case InstrJumpTrue:
+		return InstrJump{instr.addr}, cond, true
+	case InstrJumpFalse:
+		return InstrJump{instr.addr}, cond == false, true
+	}
+	return nil, false, false
+}
+
+func jumpTarget(instr Instr) (Address, bool) {
+	switch instr := instr.(type) {
+	case InstrJump:
+		return instr.addr, true
+	case InstrJumpTrue:
+		return instr.addr, true
+	case InstrJumpFalse:
+		return instr.addr, true
+	case InstrJumpNotVariant:
+		return instr.addr, true
+	}
+	return 0, false
+}
+
+func retarget(instr Instr, addr Address) Instr {
+	switch instr := instr.(type) {
+	case InstrJump:
+		return InstrJump{addr}
+	case InstrJumpTrue:
+		return InstrJumpTrue{addr}
+	case InstrJumpFalse:
+		return InstrJumpFalse{addr}
+	case InstrJumpNotVariant:
+		return InstrJumpNotVariant{instr.Tag, addr}
+	}
+	return instr
+}
+
+func jumpTargets(instrs []Instr) map[Address]bool {
+	targets := make(map[Address]bool)
+	for _, instr := range instrs {
+		if target, ok := jumpTarget(instr); ok {
+			targets[target] = true
+		}
+	}
+	return targets
+}
+
+// reachableInstrs follows every path through the bytecode starting from the
+// first instruction
+func reachableInstrs(instrs []Instr) []bool {
+	reachable := make([]bool, len(instrs))
+	pending := []Address{0}
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if int(addr) >= len(instrs) || reachable[addr] {
+			continue
+		}
+		reachable[addr] = true
+
+		switch instr := instrs[addr].(type) {
+		case InstrHalt, InstrReturn:
+			continue
+		case InstrJump:
+			pending = append(pending, instr.addr)
+			continue
+		}
+
+		if target, ok := jumpTarget(instrs[addr]); ok {
+			pending = append(pending, target)
+		}
+		pending = append(pending, addr+1)
+	}
+	return reachable
+}
@@ -0,0 +1,180 @@
+package lang
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOptimizeConstantFolding(t *testing.T) {
+	expectOptimized(t, "let a := 1 + 2 * 3;",
+		"push    7 (1:12)",
+		"store   a       0       0 (1:1)")
+
+	expectOptimized(t, `let a := -(2.5) < 1.0 == !false;`,
+		"push    true (1:23)",
+		"store   a       0       0 (1:1)")
+
+	expectOptimized(t, `let a := "a" + "b";`,
+		`push    "ab" (1:14)`,
+		"store   a       0       0 (1:1)")
+
+	// Operations that fail at runtime are left for the VM to report
+	expectOptimized(t, "let a := 1 / 0;",
+		"push    1 (1:10)",
+		"push    0 (1:14)",
+		"div (1:12)",
+		"store   a       0       0 (1:1)")
+}
+
+func TestOptimizeDeadBranches(t *testing.T) {
+	expectOptimized(t, "let a := 1; if false { a := 2; } else { a := 3; };",
+		"push    1 (1:10)",
+		"store   a       0       0 (1:1)",
+		"push    3 (1:46)",
+		"store   a       0       0 (1:41)")
+
+	expectOptimized(t, "let a := 1; if true || a > 2 { a := 2; };",
+		"push    1 (1:10)",
+		"store   a       0       0 (1:1)",
+		"push    2 (1:37)",
+		"store   a       0       0 (1:32)")
+
+	expectOptimized(t, "let a := 1; while false { a := 2; };",
+		"push    1 (1:10)",
+		"store   a       0       0 (1:1)")
+}
+
+func TestOptimizePeephole(t *testing.T) {
+	expectOptimized(t, "let a := 1; a := a + 1;",
+		"push    1 (1:10)",
+		"store   a       0       0 (1:1)",
+		"load    a       0       0 (1:18)",
+		"push    1 (1:22)",
+		"add (1:20)",
+		"store   a       0       0 (1:13)")
+
+	blob := Bytecode{}
+	blob.write(InstrNOP{})
+	blob.write(InstrPush{&ObjectInt{1}})
+	blob.write(InstrPop{})
+	blob.write(InstrLoad{"a", 0, 0})
+	blob.write(InstrCopy{})
+	blob.write(InstrPop{})
+	blob.write(InstrPop{})
+	blob.write(InstrLoadSelf{})
+	blob.write(InstrReturn{})
+
+	optimized := optimize(blob)
+	expectString(t, optimized.String(), strings.Join([]string{
+		"0x0000 self",
+		"0x0001 ret",
+	}, "\n"))
+}
+
+func TestOptimizeJumpRelocation(t *testing.T) {
+	blob := Bytecode{}
+	blob.write(InstrLoad{"a", 0, 0})
+	blob.write(InstrJumpFalse{6})
+	blob.write(InstrPush{&ObjectInt{1}})
+	blob.write(InstrPush{&ObjectInt{2}})
+	blob.write(InstrAdd{})
+	blob.write(InstrJump{8})
+	blob.write(InstrPush{&ObjectInt{3}})
+	blob.write(InstrJump{8})
+	blob.write(InstrNeg{})
+	blob.write(InstrJump{0})
+	blob.write(InstrPush{&ObjectInt{4}})
+	blob.write(InstrHalt{})
+
+	optimized := optimize(blob)
+	expectString(t, optimized.String(), strings.Join([]string{
+		"0x0000 load    a       0       0",
+		"0x0001 jmpf    0x0004",
+		"0x0002 push    3",
+		"0x0003 jmp     0x0005",
+		"0x0004 push    3",
+		"0x0005 neg",
+		"0x0006 jmp     0x0000",
+	}, "\n"))
+
+	// Jumps into the middle of a sequence prevent it from being folded
+	blob = Bytecode{}
+	blob.write(InstrLoad{"a", 0, 0})
+	blob.write(InstrJumpFalse{4})
+	blob.write(InstrPush{&ObjectInt{1}})
+	blob.write(InstrJump{5})
+	blob.write(InstrPush{&ObjectInt{2}})
+	blob.write(InstrNeg{})
+	blob.write(InstrReturn{})
+
+	optimized = optimize(blob)
+	expectString(t, optimized.String(), strings.Join([]string{
+		"0x0000 load    a       0       0",
+		"0x0001 jmpf    0x0004",
+		"0x0002 push    1",
+		"0x0003 jmp     0x0005",
+		"0x0004 push    2",
+		"0x0005 neg",
+		"0x0006 ret",
+	}, "\n"))
+}
+
+func TestOptimizeFunctions(t *testing.T) {
+	mod := compileProgram(t, "", "let f := fn (): Int { if false { return 1; }; return 2 * 3; };", nil, OptNone)
+	unoptimized := *mod.bytecode
+	optimized := Compile(mod, OptBasic)
+
+	fn := optimized.Instructions[1].(InstrPush).Val.(*ObjectFunction)
+	expectString(t, fn.bytecode.String(), strings.Join([]string{
+		"0x0000 push    6",
+		"0x0001 ret",
+	}, "\n"))
+
+	// The unoptimized function is left untouched
+	fn = unoptimized.Instructions[1].(InstrPush).Val.(*ObjectFunction)
+	if len(fn.bytecode.Instructions) <= 2 {
+		t.Errorf("Expected unoptimized function to be unchanged, got\n%s", fn.bytecode.String())
+	}
+}
+
+func TestOptimizeDependencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "optimize")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "util.plaid"), []byte("pub let n := 1 + 2;"), 0644)
+	src := `use "util.plaid"; let m := util.n;`
+
+	// Dependencies are compiled at the same level as the module using them
+	mod := compileProgram(t, filepath.Join(dir, "main.plaid"), src, nil, OptBasic)
+	dep := mod.Dependencies()[0].(*ModuleVirtual)
+	expectString(t, dep.bytecode.String(), strings.Join([]string{
+		"0x0000 alloc   n",
+		"0x0001 push    3",
+		"0x0002 store   n       0       0",
+		"0x0003 halt",
+	}, "\n"))
+}
+
+func expectOptimized(t *testing.T, src string, exp ...string) {
+	t.Helper()
+	btc := compileProgram(t, "", src, nil, OptBasic).bytecode
+	var got []string
+	for addr, instr := range btc.Instructions {
+		if pos, ok := btc.Position(Address(addr)); ok {
+			got = append(got, fmt.Sprintf("%s %s", instr, pos))
+		} else if _, ok := instr.(InstrReserve); ok == false {
+			got = append(got, instr.String())
+		}
+	}
+
+	// Every module ends with a halt instruction
+	expectString(t, strings.Join(got, "\n"), strings.Join(append(exp, "halt"), "\n"))
+}
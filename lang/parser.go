@@ -37,7 +37,11 @@ type precedence int
 const (
 	precLowest precedence = iota * 10
 	precAssign
+	precLogicalOr
+	precLogicalAnd
+	precEquality
 	precComparison
+	precCoalesce
 	precSum
 	precProduct
 	precPrefix
@@ -58,6 +62,7 @@ type postfixParseFunc func(p *parser, left Expr) (Expr, error)
 type parser struct {
 	lexer             *lexer
 	funcDepth         int
+	loopDepth         int
 	precedenceTable   map[tokType]precedence
 	prefixParseFuncs  map[tokType]prefixParseFunc
 	postfixParseFuncs map[tokType]postfixParseFunc
@@ -125,6 +130,7 @@ func makeParser(filepath string, source string) *parser {
 	p := &parser{
 		l,
 		0,
+		0,
 		make(map[tokType]precedence),
 		make(map[tokType]prefixParseFunc),
 		make(map[tokType]postfixParseFunc),
@@ -136,27 +142,41 @@ func makeParser(filepath string, source string) *parser {
 func loadGrammar(p *parser) {
 	p.registerPrefix(tokFn, parseFunction)
 	p.registerPrefix(tokBracketL, parseList)
+	p.registerPrefix(tokBraceL, parseStruct)
+	p.registerPrefix(tokMatch, parseMatch)
 	p.registerPrefix(tokParenL, parseGroup)
 	p.registerPrefix(tokPlus, parsePrefix)
 	p.registerPrefix(tokDash, parsePrefix)
+	p.registerPrefix(tokBang, parsePrefix)
+	p.registerPrefix(tokTypeof, parseTypeof)
 	p.registerPrefix(tokSelf, parseSelf)
 	p.registerPrefix(tokIdent, parseIdent)
 	p.registerPrefix(tokNumber, parseNumber)
+	p.registerPrefix(tokFloat, parseFloat)
 	p.registerPrefix(tokString, parseString)
 	p.registerPrefix(tokBoolean, parseBoolean)
+	p.registerPrefix(tokNone, parseNone)
 
 	p.registerPostfix(tokDot, parseAccess, precDispatch)
 	p.registerPostfix(tokBracketL, parseSubscript, precDispatch)
 	p.registerPostfix(tokParenL, parseDispatch, precDispatch)
 	p.registerPostfix(tokAssign, parseAssign, precAssign)
+	p.registerPostfix(tokOr, parseInfix, precLogicalOr)
+	p.registerPostfix(tokAnd, parseInfix, precLogicalAnd)
+	p.registerPostfix(tokEquals, parseInfix, precEquality)
+	p.registerPostfix(tokNotEquals, parseInfix, precEquality)
 	p.registerPostfix(tokLT, parseInfix, precComparison)
 	p.registerPostfix(tokLTEquals, parseInfix, precComparison)
 	p.registerPostfix(tokGT, parseInfix, precComparison)
 	p.registerPostfix(tokGTEquals, parseInfix, precComparison)
+	p.registerPostfix(tokAs, parseCast, precComparison)
+	p.registerPostfix(tokIs, parseIs, precComparison)
+	p.registerPostfix(tokCoalesce, parseInfix, precCoalesce)
 	p.registerPostfix(tokPlus, parseInfix, precSum)
 	p.registerPostfix(tokDash, parseInfix, precSum)
 	p.registerPostfix(tokStar, parseInfix, precProduct)
 	p.registerPostfix(tokSlash, parseInfix, precProduct)
+	p.registerPostfix(tokPercent, parseInfix, precProduct)
 }
 
 func parseProgram(p *parser) (*AST, error) {
@@ -170,6 +190,10 @@ func parseProgram(p *parser) (*AST, error) {
 			stmt, err = parseUseStmt(p)
 		case tokPub:
 			stmt, err = parsePubStmt(p)
+		case tokTypeDef:
+			stmt, err = parseTypeStmt(p)
+		case tokEnum:
+			stmt, err = parseEnumStmt(p)
 		default:
 			stmt, err = parseTopLevelStmt(p)
 		}
@@ -214,9 +238,19 @@ func parseGeneralStmt(p *parser) (Stmt, error) {
 	switch p.lexer.peek().Type {
 	case tokUse:
 		return nil, p.errorFromPeekToken("use statements must be outside any other statement")
+	case tokTypeDef:
+		return nil, p.errorFromPeekToken("type declarations must be outside any other statement")
+	case tokEnum:
+		return nil, p.errorFromPeekToken("enum declarations must be outside any other statement")
 	case tokIf:
 		return parseIfStmt(p)
-	case tokLet:
+	case tokWhile:
+		return parseWhileStmt(p)
+	case tokBreak:
+		return parseBreakStmt(p)
+	case tokContinue:
+		return parseContinueStmt(p)
+	case tokLet, tokConst:
 		return parseDeclarationStmt(p)
 	default:
 		return parseExprStmt(p)
@@ -322,17 +356,159 @@ func parsePubStmt(p *parser) (Stmt, error) {
 		return nil, err
 	}
 
-	var decl *DeclarationStmt
-	if stmt, err := parseDeclarationStmt(p); err == nil {
-		decl = stmt.(*DeclarationStmt)
-	} else {
+	var stmt Stmt
+	switch p.lexer.peek().Type {
+	case tokTypeDef:
+		stmt, err = parseTypeStmt(p)
+	case tokEnum:
+		stmt, err = parseEnumStmt(p)
+	default:
+		stmt, err = parseDeclarationStmt(p)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PubStmt{tok, stmt}, nil
+}
+
+func parseEnumStmt(p *parser) (Stmt, error) {
+	tok, err := p.expectNextToken(tokEnum, "expected ENUM keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := parseIdent(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expectNextToken(tokBraceL, "expected left brace")
+	if err != nil {
+		return nil, err
+	}
+
+	variants := []EnumVariant{}
+	for p.peekTokenIsNot(tokBraceR, tokError, tokEOF) {
+		var variant EnumVariant
+		if variant, err = parseEnumVariant(p); err != nil {
+			return nil, err
+		}
+
+		for _, prev := range variants {
+			if prev.Name.Name == variant.Name.Name {
+				msg := fmt.Sprintf("duplicate variant '%s'", prev.Name.Name)
+				return nil, p.errorFromLocation(variant.Name.Start(), msg)
+			}
+		}
+
+		variants = append(variants, variant)
+
+		// Commas between variants are optional
+		if p.lexer.peek().Type == tokComma {
+			p.lexer.next()
+		}
+	}
+
+	if len(variants) == 0 {
+		return nil, p.errorFromPeekToken("expected at least one variant")
+	}
+
+	_, err = p.expectNextToken(tokBraceR, "expected right brace")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expectNextToken(tokSemi, "expected semicolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnumStmt{tok, name.(*IdentExpr), variants}, nil
+}
+
+func parseEnumVariant(p *parser) (EnumVariant, error) {
+	name, err := parseIdent(p)
+	if err != nil {
+		return EnumVariant{}, err
+	}
+
+	payload := []TypeNote{}
+	if p.lexer.peek().Type == tokParenL {
+		p.lexer.next()
+
+		for p.peekTokenIsNot(tokParenR, tokError, tokEOF) {
+			var note TypeNote
+			if note, err = parseTypeNote(p); err != nil {
+				return EnumVariant{}, err
+			}
+
+			payload = append(payload, note)
+
+			if p.peekTokenIsNot(tokComma) {
+				break
+			} else {
+				p.lexer.next()
+			}
+		}
+
+		_, err = p.expectNextToken(tokParenR, "expected right paren")
+		if err != nil {
+			return EnumVariant{}, err
+		}
+	}
+
+	return EnumVariant{name.(*IdentExpr), payload}, nil
+}
+
+func parseTypeStmt(p *parser) (Stmt, error) {
+	tok, err := p.expectNextToken(tokTypeDef, "expected TYPE keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := parseIdent(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expectNextToken(tokAssign, "expected :=")
+	if err != nil {
 		return nil, err
 	}
 
-	return &PubStmt{tok, decl}, nil
+	note, err := parseTypeNote(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expectNextToken(tokSemi, "expected semicolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypeStmt{tok, name.(*IdentExpr), note}, nil
 }
 
 func parseIfStmt(p *parser) (Stmt, error) {
+	stmt, err := parseIfChain(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expectNextToken(tokSemi, "expected semicolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// parseIfChain parses an if-clause and any else-if or else clauses that follow
+// it. Only the outermost if statement is followed by a semicolon so the
+// semicolon is left for the caller to consume.
+func parseIfChain(p *parser) (*IfStmt, error) {
 	tok, err := p.expectNextToken(tokIf, "expected IF keyword")
 	if err != nil {
 		return nil, err
@@ -348,16 +524,97 @@ func parseIfStmt(p *parser) (Stmt, error) {
 		return nil, err
 	}
 
+	if p.peekTokenIsNot(tokElse) {
+		return &IfStmt{tok, cond, clause, nil}, nil
+	}
+
+	p.lexer.next()
+
+	var alt Stmt
+	if p.lexer.peek().Type == tokIf {
+		alt, err = parseIfChain(p)
+	} else {
+		alt, err = parseStmtBlock(p)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &IfStmt{tok, cond, clause, alt}, nil
+}
+
+func parseWhileStmt(p *parser) (Stmt, error) {
+	tok, err := p.expectNextToken(tokWhile, "expected WHILE keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	var cond Expr
+	if cond, err = parseExpr(p, precLowest); err != nil {
+		return nil, err
+	}
+
+	p.loopDepth++
+	var clause *StmtBlock
+	if clause, err = parseStmtBlock(p); err != nil {
+		return nil, err
+	}
+	p.loopDepth--
+
+	_, err = p.expectNextToken(tokSemi, "expected semicolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &WhileStmt{tok, cond, clause}, nil
+}
+
+func parseBreakStmt(p *parser) (Stmt, error) {
+	tok, err := p.expectNextToken(tokBreak, "expected BREAK keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.loopDepth == 0 {
+		return nil, p.errorFromLocation(tok.Loc, "break statements must be inside a loop")
+	}
+
+	_, err = p.expectNextToken(tokSemi, "expected semicolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &BreakStmt{tok}, nil
+}
+
+func parseContinueStmt(p *parser) (Stmt, error) {
+	tok, err := p.expectNextToken(tokContinue, "expected CONTINUE keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.loopDepth == 0 {
+		return nil, p.errorFromLocation(tok.Loc, "continue statements must be inside a loop")
+	}
+
 	_, err = p.expectNextToken(tokSemi, "expected semicolon")
 	if err != nil {
 		return nil, err
 	}
 
-	return &IfStmt{tok, cond, clause}, nil
+	return &ContinueStmt{tok}, nil
 }
 
 func parseDeclarationStmt(p *parser) (Stmt, error) {
-	tok, err := p.expectNextToken(tokLet, "expected LET keyword")
+	var tok token
+	var err error
+	if p.lexer.peek().Type == tokConst {
+		tok, err = p.expectNextToken(tokConst, "expected CONST keyword")
+	} else {
+		tok, err = p.expectNextToken(tokLet, "expected LET keyword")
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -369,6 +626,14 @@ func parseDeclarationStmt(p *parser) (Stmt, error) {
 
 	name := expr.(*IdentExpr)
 
+	var note TypeNote
+	if p.lexer.peek().Type == tokColon {
+		p.lexer.next()
+		if note, err = parseTypeNote(p); err != nil {
+			return nil, err
+		}
+	}
+
 	_, err = p.expectNextToken(tokAssign, "expected :=")
 	if err != nil {
 		return nil, err
@@ -383,7 +648,7 @@ func parseDeclarationStmt(p *parser) (Stmt, error) {
 		return nil, err
 	}
 
-	return &DeclarationStmt{tok, name, expr}, nil
+	return &DeclarationStmt{tok, name, note, expr}, nil
 }
 
 func parseReturnStmt(p *parser) (Stmt, error) {
@@ -420,6 +685,8 @@ func parseExprStmt(p *parser) (Stmt, error) {
 		stmt = &ExprStmt{expr}
 	case *AssignExpr:
 		stmt = &ExprStmt{expr}
+	case *FieldAssignExpr:
+		stmt = &ExprStmt{expr}
 	default:
 		return nil, p.errorFromLocation(expr.Start(), "expected start of statement")
 	}
@@ -433,9 +700,36 @@ func parseExprStmt(p *parser) (Stmt, error) {
 }
 
 func parseTypeNote(p *parser) (TypeNote, error) {
-	var child TypeNote
-	var err error
+	child, err := parseTypeNoteBase(p)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.lexer.peek().Type == tokQuestion || p.lexer.peek().Type == tokCoalesce {
+		child, _ = parseTypeNoteOptional(p, child)
+	}
 
+	return child, nil
+}
+
+// parseTypeNoteOperand parses the type note on the right side of an `as` or
+// `is` operator. A `??` after the type is left for the coalescing operator
+// instead of being split into two optional levels
+func parseTypeNoteOperand(p *parser) (TypeNote, error) {
+	child, err := parseTypeNoteBase(p)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.lexer.peek().Type == tokQuestion {
+		child, _ = parseTypeNoteOptional(p, child)
+	}
+
+	return child, nil
+}
+
+// parseTypeNoteBase parses a type note without any optional suffixes
+func parseTypeNoteBase(p *parser) (child TypeNote, err error) {
 	switch p.lexer.peek().Type {
 	case tokIdent:
 		child, err = parseTypeNoteIdent(p)
@@ -443,21 +737,15 @@ func parseTypeNote(p *parser) (TypeNote, error) {
 		child, err = parseTypeNoteList(p)
 	case tokParenL:
 		child, err = parseTypeNoteTuple(p)
+	case tokBraceL:
+		child, err = parseTypeNoteStruct(p)
 	case tokError:
 		return nil, p.errorFromPeekToken(p.lexer.peek().Lexeme)
 	default:
 		return nil, p.errorFromPeekToken("unexpected symbol")
 	}
 
-	if err != nil {
-		return nil, err
-	}
-
-	for p.lexer.peek().Type == tokQuestion {
-		child, _ = parseTypeNoteOptional(p, child)
-	}
-
-	return child, nil
+	return child, err
 }
 
 func parseTypeNoteIdent(p *parser) (TypeNote, error) {
@@ -472,9 +760,20 @@ func parseTypeNoteIdent(p *parser) (TypeNote, error) {
 		return TypeNoteAny{tok}, nil
 	case "Void":
 		return TypeNoteVoid{tok}, nil
-	default:
-		return TypeNoteIdent{tok, tok.Lexeme}, nil
 	}
+
+	if p.lexer.peek().Type == tokDot {
+		p.lexer.next()
+
+		var name token
+		if name, err = p.expectNextToken(tokIdent, "expected identifier"); err != nil {
+			return nil, err
+		}
+
+		return TypeNoteQualified{tok, tok.Lexeme, name.Lexeme}, nil
+	}
+
+	return TypeNoteIdent{tok, tok.Lexeme}, nil
 }
 
 func parseTypeNoteList(p *parser) (TypeNote, error) {
@@ -497,6 +796,13 @@ func parseTypeNoteList(p *parser) (TypeNote, error) {
 }
 
 func parseTypeNoteOptional(p *parser, child TypeNote) (TypeNote, error) {
+	if p.lexer.peek().Type == tokCoalesce {
+		// The lexer reads `??` as a single operator so inside a type note it
+		// has to be split into two optional levels.
+		tok := p.lexer.next()
+		return TypeNoteOptional{tok, TypeNoteOptional{tok, child}}, nil
+	}
+
 	tok, err := p.expectNextToken(tokQuestion, "expected question mark")
 	if err != nil {
 		return nil, err
@@ -505,6 +811,51 @@ func parseTypeNoteOptional(p *parser, child TypeNote) (TypeNote, error) {
 	return TypeNoteOptional{tok, child}, nil
 }
 
+func parseTypeNoteStruct(p *parser) (TypeNote, error) {
+	tok, err := p.expectNextToken(tokBraceL, "expected left brace")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []TypeNoteField{}
+	for p.peekTokenIsNot(tokBraceR, tokError, tokEOF) {
+		var name Expr
+		if name, err = parseIdent(p); err != nil {
+			return nil, err
+		}
+
+		for _, field := range fields {
+			if field.Name.Name == name.(*IdentExpr).Name {
+				msg := fmt.Sprintf("duplicate field '%s'", field.Name.Name)
+				return nil, p.errorFromLocation(name.Start(), msg)
+			}
+		}
+
+		if _, err = p.expectNextToken(tokColon, "expected colon between field name and type"); err != nil {
+			return nil, err
+		}
+
+		var note TypeNote
+		if note, err = parseTypeNote(p); err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, TypeNoteField{name.(*IdentExpr), note})
+
+		// Commas between fields are optional
+		if p.lexer.peek().Type == tokComma {
+			p.lexer.next()
+		}
+	}
+
+	_, err = p.expectNextToken(tokBraceR, "expected right brace")
+	if err != nil {
+		return nil, err
+	}
+
+	return TypeNoteStruct{tok, fields}, nil
+}
+
 func parseTypeNoteTuple(p *parser) (TypeNote, error) {
 	tok, err := p.expectNextToken(tokParenL, "expected left paren")
 	if err != nil {
@@ -588,19 +939,72 @@ func parseFunction(p *parser) (Expr, error) {
 		return nil, err
 	}
 
+	var typeParams []*IdentExpr
+	if p.peekTokenIsNot(tokLT) == false {
+		if typeParams, err = parseFunctionTypeParams(p); err != nil {
+			return nil, err
+		}
+	}
+
 	params, ret, err := parseFunctionSignature(p)
 	if err != nil {
 		return nil, err
 	}
 
+	// Loops outside of the function cannot be the target of a break or
+	// continue statement inside of the function.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	p.funcDepth++
 	block, err := parseStmtBlock(p)
 	if err != nil {
 		return nil, err
 	}
 	p.funcDepth--
+	p.loopDepth = loopDepth
+
+	return &FunctionExpr{tok, typeParams, params, ret, block}, nil
+}
+
+func parseFunctionTypeParams(p *parser) ([]*IdentExpr, error) {
+	_, err := p.expectNextToken(tokLT, "expected left angle bracket")
+	if err != nil {
+		return nil, err
+	}
+
+	params := []*IdentExpr{}
+	for p.peekTokenIsNot(tokGT, tokEOF, tokError) {
+		var param Expr
+		if param, err = parseIdent(p); err != nil {
+			return nil, err
+		}
+
+		for _, prev := range params {
+			if prev.Name == param.(*IdentExpr).Name {
+				msg := fmt.Sprintf("duplicate type parameter '%s'", prev.Name)
+				return nil, p.errorFromLocation(param.Start(), msg)
+			}
+		}
+
+		params = append(params, param.(*IdentExpr))
+
+		if p.peekTokenIsNot(tokComma) {
+			break
+		} else {
+			p.lexer.next()
+		}
+	}
+
+	if len(params) == 0 {
+		return nil, p.errorFromPeekToken("expected type parameter")
+	}
+
+	_, err = p.expectNextToken(tokGT, "expected right angle bracket")
+	if err != nil {
+		return nil, err
+	}
 
-	return &FunctionExpr{tok, params, ret, block}, nil
+	return params, nil
 }
 
 func parseFunctionSignature(p *parser) ([]*FunctionParam, TypeNote, error) {
@@ -750,6 +1154,159 @@ func parseSubscript(p *parser, left Expr) (Expr, error) {
 	return &SubscriptExpr{left, index}, nil
 }
 
+func parseStruct(p *parser) (Expr, error) {
+	tok, err := p.expectNextToken(tokBraceL, "expected left brace")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []StructField{}
+	for p.peekTokenIsNot(tokBraceR, tokError, tokEOF) {
+		var name Expr
+		if name, err = parseIdent(p); err != nil {
+			return nil, err
+		}
+
+		for _, field := range fields {
+			if field.Name.Name == name.(*IdentExpr).Name {
+				msg := fmt.Sprintf("duplicate field '%s'", field.Name.Name)
+				return nil, p.errorFromLocation(name.Start(), msg)
+			}
+		}
+
+		if _, err = p.expectNextToken(tokColon, "expected colon between field name and value"); err != nil {
+			return nil, err
+		}
+
+		var expr Expr
+		if expr, err = parseExpr(p, precLowest); err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, StructField{name.(*IdentExpr), expr})
+
+		// Commas between fields are optional
+		if p.lexer.peek().Type == tokComma {
+			p.lexer.next()
+		}
+	}
+
+	_, err = p.expectNextToken(tokBraceR, "expected right brace")
+	if err != nil {
+		return nil, err
+	}
+
+	return &StructExpr{tok, fields}, nil
+}
+
+func parseMatch(p *parser) (Expr, error) {
+	tok, err := p.expectNextToken(tokMatch, "expected MATCH keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := parseExpr(p, precLowest)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expectNextToken(tokBraceL, "expected left brace")
+	if err != nil {
+		return nil, err
+	}
+
+	arms := []*MatchArm{}
+	for p.peekTokenIsNot(tokBraceR, tokError, tokEOF) {
+		var arm *MatchArm
+		if arm, err = parseMatchArm(p); err != nil {
+			return nil, err
+		}
+
+		arms = append(arms, arm)
+
+		// Commas between arms are optional
+		if p.lexer.peek().Type == tokComma {
+			p.lexer.next()
+		}
+	}
+
+	_, err = p.expectNextToken(tokBraceR, "expected right brace")
+	if err != nil {
+		return nil, err
+	}
+
+	return &MatchExpr{tok, subject, arms}, nil
+}
+
+func parseMatchArm(p *parser) (*MatchArm, error) {
+	tok := p.lexer.peek()
+	arm := &MatchArm{Tok: tok, Bindings: []*IdentExpr{}}
+
+	if tok.Type == tokElse {
+		p.lexer.next()
+	} else {
+		variant, err := parseIdent(p)
+		if err != nil {
+			return nil, err
+		}
+		arm.Variant = variant.(*IdentExpr)
+
+		if p.lexer.peek().Type == tokParenL {
+			if arm.Bindings, err = parseMatchBindings(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err := p.expectNextToken(tokArrow, "expected =>")
+	if err != nil {
+		return nil, err
+	}
+
+	if arm.Expr, err = parseExpr(p, precLowest); err != nil {
+		return nil, err
+	}
+
+	return arm, nil
+}
+
+func parseMatchBindings(p *parser) ([]*IdentExpr, error) {
+	_, err := p.expectNextToken(tokParenL, "expected left paren")
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := []*IdentExpr{}
+	for p.peekTokenIsNot(tokParenR, tokError, tokEOF) {
+		var binding Expr
+		if binding, err = parseIdent(p); err != nil {
+			return nil, err
+		}
+
+		for _, prev := range bindings {
+			if prev.Name == binding.(*IdentExpr).Name {
+				msg := fmt.Sprintf("duplicate binding '%s'", prev.Name)
+				return nil, p.errorFromLocation(binding.Start(), msg)
+			}
+		}
+
+		bindings = append(bindings, binding.(*IdentExpr))
+
+		if p.peekTokenIsNot(tokComma) {
+			break
+		} else {
+			p.lexer.next()
+		}
+	}
+
+	_, err = p.expectNextToken(tokParenR, "expected right paren")
+	if err != nil {
+		return nil, err
+	}
+
+	return bindings, nil
+}
+
 func parseAccess(p *parser, left Expr) (Expr, error) {
 	_, err := p.expectNextToken(tokDot, "expect dot")
 	if err != nil {
@@ -795,9 +1352,11 @@ func parseDispatch(p *parser, left Expr) (Expr, error) {
 }
 
 func parseAssign(p *parser, left Expr) (Expr, error) {
-	leftIdent, ok := left.(*IdentExpr)
-	if ok == false {
-		return nil, p.errorFromLocation(left.Start(), "left hand must be an identifier")
+	switch left.(type) {
+	case *IdentExpr, *AccessExpr:
+		// Both variables and struct fields can be assigned to
+	default:
+		return nil, p.errorFromLocation(left.Start(), "left hand must be an identifier or a field")
 	}
 
 	level := p.peekPrecedence()
@@ -807,7 +1366,11 @@ func parseAssign(p *parser, left Expr) (Expr, error) {
 		return nil, err
 	}
 
-	return &AssignExpr{tok, leftIdent, right}, nil
+	if access, ok := left.(*AccessExpr); ok {
+		return &FieldAssignExpr{tok, access, right}, nil
+	}
+
+	return &AssignExpr{tok, left.(*IdentExpr), right}, nil
 }
 
 func parsePostfix(p *parser, left Expr) (Expr, error) {
@@ -828,6 +1391,48 @@ func parsePrefix(p *parser) (Expr, error) {
 	return &UnaryExpr{oper, tok, right}, nil
 }
 
+func parseTypeof(p *parser) (Expr, error) {
+	tok, err := p.expectNextToken(tokTypeof, "expected TYPEOF keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := parseExpr(p, precPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypeofExpr{tok, expr}, nil
+}
+
+func parseCast(p *parser, left Expr) (Expr, error) {
+	tok, err := p.expectNextToken(tokAs, "expected AS keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := parseTypeNoteOperand(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CastExpr{tok, left, note}, nil
+}
+
+func parseIs(p *parser, left Expr) (Expr, error) {
+	tok, err := p.expectNextToken(tokIs, "expected IS keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := parseTypeNoteOperand(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IsExpr{tok, left, note}, nil
+}
+
 func parseGroup(p *parser) (Expr, error) {
 	_, err := p.expectNextToken(tokParenL, "expected left paren")
 	if err != nil {
@@ -883,6 +1488,24 @@ func evalNumber(p *parser, tok token) (*NumberExpr, error) {
 	return &NumberExpr{tok, int(val)}, nil
 }
 
+func parseFloat(p *parser) (Expr, error) {
+	tok, err := p.expectNextToken(tokFloat, "expected float literal")
+	if err != nil {
+		return nil, err
+	}
+
+	return evalFloat(p, tok)
+}
+
+func evalFloat(p *parser, tok token) (*FloatExpr, error) {
+	val, err := strconv.ParseFloat(tok.Lexeme, 64)
+	if err != nil {
+		return nil, p.errorFromLocation(tok.Loc, "malformed float literal")
+	}
+
+	return &FloatExpr{tok, val}, nil
+}
+
 func parseString(p *parser) (Expr, error) {
 	tok, err := p.expectNextToken(tokString, "expected string literal")
 	if err != nil {
@@ -942,6 +1565,15 @@ func parseBoolean(p *parser) (Expr, error) {
 	return evalBoolean(p, tok)
 }
 
+func parseNone(p *parser) (Expr, error) {
+	tok, err := p.expectNextToken(tokNone, "expected none")
+	if err != nil {
+		return nil, err
+	}
+
+	return &NoneExpr{tok}, nil
+}
+
 func evalBoolean(p *parser, tok token) (*BooleanExpr, error) {
 	if tok.Lexeme == "true" {
 		return &BooleanExpr{tok, true}, nil
@@ -163,6 +163,9 @@ func TestParseStmt(t *testing.T) {
 
 	expectStmt("if a { let a := 456; };", "(if a {\n  (let a 456)})", parseGeneralStmt)
 	expectStmt("let a := 123;", "(let a 123)", parseGeneralStmt)
+	expectStmt("let a: Int? := 123;", "(let a:Int? 123)", parseGeneralStmt)
+	expectStmt("const a: [Int] := [];", "(const a:[Int] [ ])", parseGeneralStmt)
+	expectStmtError("let a: := 123;", "(1:8) unexpected symbol", parseGeneralStmt)
 	expectStmt("return 123;", "(return 123)", parseNonTopLevelStmt)
 	expectStmtError("123 + 456", "(1:1) expected start of statement", parseStmt)
 	expectStmtError("123 + 456", "(1:1) expected start of statement", parseTopLevelStmt)
@@ -257,11 +260,48 @@ func TestParsePubStmt(t *testing.T) {
 
 	good(`pub let a := 123;`, `(pub (let a 123))`)
 	good(`pub let x := "abc";`, `(pub (let x "abc"))`)
+	good(`pub type Id := Int;`, `(pub (type Id Int))`)
+	good(`pub const max := 10;`, `(pub (const max 10))`)
 
 	bad(`pbu let a := 123;`, "(1:1) expected PUB keyword")
 	bad(`pub a := 123;`, "(1:5) expected LET keyword")
 }
 
+func TestParseTypeStmt(t *testing.T) {
+	good := func(source string, ast string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		stmt, err := parseTypeStmt(p)
+		expectNoParserErrors(t, ast, stmt, err)
+		expectStart(t, stmt, 1, 1)
+	}
+
+	bad := func(source string, msg string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		stmt, err := parseTypeStmt(p)
+		expectParserError(t, msg, stmt, err)
+	}
+
+	good(`type Point := {x: Int y: Int};`, `(type Point {x:Int y:Int})`)
+	good(`type Handler := (Int) => Void;`, `(type Handler (Int) => Void)`)
+	good(`type Pos := geo.Point?;`, `(type Pos geo.Point?)`)
+
+	bad(`typ Point := Int;`, "(1:1) expected TYPE keyword")
+	bad(`type := Int;`, "(1:6) expected identifier")
+	bad(`type Point Int;`, "(1:12) expected :=")
+	bad(`type Point := ;`, "(1:15) unexpected symbol")
+	bad(`type Point := Int`, "(1:17) expected semicolon")
+	bad(`type Pos := geo.;`, "(1:17) expected identifier")
+
+	p := makeParser("", `if true { type A := Int; };`)
+	loadGrammar(p)
+	_, err := parseProgram(p)
+	expectAnError(t, err, "(1:11) type declarations must be outside any other statement")
+}
+
 func TestParseIfStmt(t *testing.T) {
 	expectIf := func(source string, ast string) {
 		p := makeParser("", source)
@@ -280,12 +320,92 @@ func TestParseIfStmt(t *testing.T) {
 
 	expectIf("if true {};", "(if true {})")
 	expectIf("if true { let a := 123; };", "(if true {\n  (let a 123)})")
+	expectIf("if a {} else {};", "(if a {} else {})")
+	expectIf("if a { let b := 1; } else { let b := 2; };", "(if a {\n  (let b 1)} else {\n  (let b 2)})")
+	expectIf("if a {} else if b {};", "(if a {} else (if b {}))")
+	expectIf("if a {} else if b {} else {};", "(if a {} else (if b {} else {}))")
 	expectIfError("iff true { let a := 123; };", "(1:1) expected IF keyword")
+	expectIfError("if true {} else;", "(1:16) expected left brace")
+	expectIfError("if true {} else {}", "(1:18) expected semicolon")
+	expectIfError("if true {} else if false {}", "(1:27) expected semicolon")
 	expectIfError("if let { let a := 123; };", "(1:4) unexpected symbol")
 	expectIfError("if true { let a := 123 };", "(1:24) expected semicolon")
 	expectIfError("if true { let a := 123; }", "(1:25) expected semicolon")
 }
 
+func TestParseWhileStmt(t *testing.T) {
+	good := func(source string, ast string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		stmt, err := parseWhileStmt(p)
+		expectNoParserErrors(t, ast, stmt, err)
+		expectStart(t, stmt, 1, 1)
+	}
+
+	bad := func(source string, msg string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		stmt, err := parseWhileStmt(p)
+		expectParserError(t, msg, stmt, err)
+	}
+
+	good("while true {};", "(while true {})")
+	good("while a < 10 { break; };", "(while (< a 10) {\n  (break)})")
+	good("while a { if b { continue; }; };", "(while a {\n  (if b {\n    (continue)})})")
+	good("while a { while b { break; }; break; };", "(while a {\n  (while b {\n    (break)})\n  (break)})")
+	bad("whilst true {};", "(1:1) expected WHILE keyword")
+	bad("while let {};", "(1:7) unexpected symbol")
+	bad("while true { let a := 123 };", "(1:27) expected semicolon")
+	bad("while true {}", "(1:13) expected semicolon")
+	bad("while true { let f := fn (): Void { break; }; };", "(1:37) break statements must be inside a loop")
+}
+
+func TestParseBreakStmt(t *testing.T) {
+	p := makeParser("", "break;")
+	p.loopDepth++
+	stmt, err := parseBreakStmt(p)
+	expectNoParserErrors(t, "(break)", stmt, err)
+	expectStart(t, stmt, 1, 1)
+
+	p = makeParser("", "break;")
+	stmt, err = parseBreakStmt(p)
+	expectParserError(t, "(1:1) break statements must be inside a loop", stmt, err)
+
+	p = makeParser("", "break")
+	p.loopDepth++
+	stmt, err = parseBreakStmt(p)
+	expectParserError(t, "(1:5) expected semicolon", stmt, err)
+
+	p = makeParser("", "continue;")
+	p.loopDepth++
+	stmt, err = parseBreakStmt(p)
+	expectParserError(t, "(1:1) expected BREAK keyword", stmt, err)
+}
+
+func TestParseContinueStmt(t *testing.T) {
+	p := makeParser("", "continue;")
+	p.loopDepth++
+	stmt, err := parseContinueStmt(p)
+	expectNoParserErrors(t, "(continue)", stmt, err)
+	expectStart(t, stmt, 1, 1)
+
+	p = makeParser("", "continue;")
+	stmt, err = parseContinueStmt(p)
+	expectParserError(t, "(1:1) continue statements must be inside a loop", stmt, err)
+
+	p = makeParser("", "continue")
+	p.loopDepth++
+	stmt, err = parseContinueStmt(p)
+	expectParserError(t, "(1:8) expected semicolon", stmt, err)
+
+	p = makeParser("", "break;")
+	p.loopDepth++
+	stmt, err = parseContinueStmt(p)
+	expectParserError(t, "(1:1) expected CONTINUE keyword", stmt, err)
+}
+
 func TestParseDeclarationStmt(t *testing.T) {
 	p := makeParser("", "let a := 123;")
 	p.registerPrefix(tokNumber, parseNumber)
@@ -317,6 +437,17 @@ func TestParseDeclarationStmt(t *testing.T) {
 	p.registerPrefix(tokNumber, parseNumber)
 	stmt, err = parseDeclarationStmt(p)
 	expectParserError(t, "(1:12) expected semicolon", stmt, err)
+
+	p = makeParser("", "const a := 123;")
+	p.registerPrefix(tokNumber, parseNumber)
+	stmt, err = parseDeclarationStmt(p)
+	expectNoParserErrors(t, "(const a 123)", stmt, err)
+	expectStart(t, stmt, 1, 1)
+
+	p = makeParser("", "const := 123;")
+	p.registerPrefix(tokNumber, parseNumber)
+	stmt, err = parseDeclarationStmt(p)
+	expectParserError(t, "(1:7) expected identifier", stmt, err)
 }
 
 func TestParseReturnStmt(t *testing.T) {
@@ -357,6 +488,12 @@ func TestParseExprStmt(t *testing.T) {
 	expectNoParserErrors(t, "(callee (1 2))", stmt, err)
 	expectStart(t, stmt, 1, 1)
 
+	p = makeParser("", "a.b := 123;")
+	loadGrammar(p)
+	stmt, err = parseExprStmt(p)
+	expectNoParserErrors(t, "(= (a).b 123)", stmt, err)
+	expectStart(t, stmt, 1, 1)
+
 	p = makeParser("", "a := 123")
 	loadGrammar(p)
 	stmt, err = parseExprStmt(p)
@@ -385,11 +522,17 @@ func TestParseTypeSig(t *testing.T) {
 	expectTypeNote(t, parseTypeNote, "([Int]?, Bool)", "([Int]? Bool)")
 	expectTypeNote(t, parseTypeNote, "() => [Int]?", "() => [Int]?")
 	expectTypeNote(t, parseTypeNote, "() => Void", "() => Void")
+	expectTypeNote(t, parseTypeNote, "{x: Int y: Int}", "{x:Int y:Int}")
+	expectTypeNote(t, parseTypeNote, "{x: Int, y: [Str]?}?", "{x:Int y:[Str]?}?")
+	expectTypeNote(t, parseTypeNote, "{}", "{}")
 
 	expectTypeNoteError(t, parseTypeNote, "[?]", "(1:2) unexpected symbol")
 	expectTypeNoteError(t, parseTypeNote, `[@]`, "(1:2) unexpected symbol")
 	expectTypeNoteError(t, parseTypeNote, "[Int", "(1:4) expected right bracket")
 	expectTypeNoteError(t, parseTypeNote, "?", "(1:1) unexpected symbol")
+	expectTypeNoteError(t, parseTypeNote, "{x Int}", "(1:4) expected colon between field name and type")
+	expectTypeNoteError(t, parseTypeNote, "{x: Int x: Str}", "(1:9) duplicate field 'x'")
+	expectTypeNoteError(t, parseTypeNote, "{x: Int", "(1:7) expected right brace")
 }
 
 func TestParseTypeIdent(t *testing.T) {
@@ -477,6 +620,62 @@ func TestParseExpr(t *testing.T) {
 	expr, err = parseExpr(p, precLowest)
 	expectNoParserErrors(t, "(+ a (* b c))", expr, err)
 
+	p = makeParser("", "a == b < c")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(== a (< b c))", expr, err)
+
+	p = makeParser("", "a != b == c")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(== (!= a b) c)", expr, err)
+
+	p = makeParser("", "a || b && c == d")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(|| a (&& b (== c d)))", expr, err)
+
+	p = makeParser("", "!a && b || c")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(|| (&& (! a) b) c)", expr, err)
+
+	p = makeParser("", "a ?? b + c < d")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(< (?? a (+ b c)) d)", expr, err)
+
+	p = makeParser("", "a ?? b ?? none")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(?? (?? a b) none)", expr, err)
+
+	p = makeParser("", "a is Int && b as [Str] != none")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(&& (is a Int) (!= (as b [Str]) none))", expr, err)
+
+	// A `??` after the type of a cast is the coalescing operator
+	p = makeParser("", "a as Int ?? 0")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(?? (as a Int) 0)", expr, err)
+
+	p = makeParser("", "a as [Int?]? ?? b")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(?? (as a [Int?]?) b)", expr, err)
+
+	p = makeParser("", "typeof a + b == \"Int\"")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(== (+ (typeof a) b) \"Int\")", expr, err)
+
+	p = makeParser("", "a as")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectParserError(t, "(1:4) unexpected symbol", expr, err)
+
 	p = makeParser("", "a +")
 	p.registerPostfix(tokPlus, parseInfix, precSum)
 	p.registerPrefix(tokIdent, parseIdent)
@@ -517,11 +716,39 @@ func TestParseFunction(t *testing.T) {
 
 	p = makeParser("", "fn (): { let x := 123; }")
 	expr, err = parseFunction(p)
+	expectParserError(t, "(1:10) expected identifier", expr, err)
+
+	p = makeParser("", "fn (): => { let x := 123; }")
+	expr, err = parseFunction(p)
 	expectParserError(t, "(1:8) unexpected symbol", expr, err)
 
 	p = makeParser("", "fn ():Void { let x = 123; }")
 	expr, err = parseFunction(p)
 	expectParserError(t, "(1:20) expected :=", expr, err)
+
+	p = makeParser("", "fn<T>(x:T):T {}")
+	expr, err = parseFunction(p)
+	expectNoParserErrors(t, "(fn <T> (x:T):T {})", expr, err)
+
+	p = makeParser("", "fn <K, V>(k:K, v:V):{k:K v:V} {}")
+	expr, err = parseFunction(p)
+	expectNoParserErrors(t, "(fn <K V> (k:K v:V):{k:K v:V} {})", expr, err)
+
+	p = makeParser("", "fn <>(x:Int):Int {}")
+	expr, err = parseFunction(p)
+	expectParserError(t, "(1:5) expected type parameter", expr, err)
+
+	p = makeParser("", "fn <T, T>(x:T):T {}")
+	expr, err = parseFunction(p)
+	expectParserError(t, "(1:8) duplicate type parameter 'T'", expr, err)
+
+	p = makeParser("", "fn <T(x:T):T {}")
+	expr, err = parseFunction(p)
+	expectParserError(t, "(1:6) expected right angle bracket", expr, err)
+
+	p = makeParser("", "fn <1>(x:T):T {}")
+	expr, err = parseFunction(p)
+	expectParserError(t, "(1:5) expected identifier", expr, err)
 }
 
 func TestParseFunctionParams(t *testing.T) {
@@ -695,6 +922,128 @@ func TestParseSubscript(t *testing.T) {
 	expectParserError(t, "(1:5) expect right bracket", expr, err)
 }
 
+func TestParseEnumStmt(t *testing.T) {
+	good := func(source string, ast string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		stmt, err := parseEnumStmt(p)
+		expectNoParserErrors(t, ast, stmt, err)
+		expectStart(t, stmt, 1, 1)
+	}
+
+	bad := func(source string, msg string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		stmt, err := parseEnumStmt(p)
+		expectParserError(t, msg, stmt, err)
+	}
+
+	good(`enum Shape { Circle(Float) Rect(Float, Float) Empty };`,
+		`(enum Shape Circle(Float) Rect(Float Float) Empty)`)
+	good(`enum Opt { Some([Int?]), Nothing, };`, `(enum Opt Some([Int?]) Nothing)`)
+
+	bad(`enum { A };`, "(1:6) expected identifier")
+	bad(`enum E A };`, "(1:8) expected left brace")
+	bad(`enum E {};`, "(1:9) expected at least one variant")
+	bad(`enum E { A B A };`, "(1:14) duplicate variant 'A'")
+	bad(`enum E { A(Int };`, "(1:16) expected right paren")
+	bad(`enum E { A(,) };`, "(1:12) unexpected symbol")
+	bad(`enum E { A }`, "(1:12) expected semicolon")
+
+	p := makeParser("", `let f := fn (): Void { enum E { A }; };`)
+	loadGrammar(p)
+	_, err := parseProgram(p)
+	expectAnError(t, err, "(1:24) enum declarations must be outside any other statement")
+}
+
+func TestParseMatch(t *testing.T) {
+	good := func(source string, ast string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		expr, err := parseExpr(p, precLowest)
+		expectNoParserErrors(t, ast, expr, err)
+		expectStart(t, expr, 1, 1)
+	}
+
+	bad := func(source string, msg string) {
+		t.Helper()
+		p := makeParser("", source)
+		loadGrammar(p)
+		expr, err := parseExpr(p, precLowest)
+		expectParserError(t, msg, expr, err)
+	}
+
+	good(`match s { Circle(r) => r * r, Rect(w, h) => w * h, Empty => 0.0 }`,
+		`(match s (Circle(r) (* r r)) (Rect(w h) (* w h)) (Empty 0.0))`)
+	good(`match f(x) { A => 1 else => 2 }`, `(match (f (x)) (A 1) (else 2))`)
+	good(`match s {}`, `(match s)`)
+
+	bad(`match { A => 1 }`, "(1:11) expected colon between field name and value")
+	bad(`match s A => 1 }`, "(1:9) expected left brace")
+	bad(`match s { A 1 }`, "(1:13) expected =>")
+	bad(`match s { A(x, x) => 1 }`, "(1:16) duplicate binding 'x'")
+	bad(`match s { A(1) => 1 }`, "(1:13) expected identifier")
+	bad(`match s { A(x => 1 }`, "(1:15) expected right paren")
+	bad(`match s { A => }`, "(1:16) unexpected symbol")
+	bad(`match s { A => 1`, "(1:16) expected right brace")
+}
+
+func TestParseStruct(t *testing.T) {
+	p := makeParser("", "{x: 1, y: 2}")
+	loadGrammar(p)
+	expr, err := parseStruct(p)
+	expectNoParserErrors(t, "{ x:1 y:2 }", expr, err)
+	expectStart(t, expr, 1, 1)
+
+	p = makeParser("", "{x: 1 y: a + b}")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectNoParserErrors(t, "{ x:1 y:(+ a b) }", expr, err)
+
+	p = makeParser("", "{}")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectNoParserErrors(t, "{ }", expr, err)
+
+	p = makeParser("", "{p: {q: true},}")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectNoParserErrors(t, "{ p:{ q:true } }", expr, err)
+
+	p = makeParser("", "(x: 1)")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectParserError(t, "(1:1) expected left brace", expr, err)
+
+	p = makeParser("", "{1: 2}")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectParserError(t, "(1:2) expected identifier", expr, err)
+
+	p = makeParser("", "{x 2}")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectParserError(t, "(1:4) expected colon between field name and value", expr, err)
+
+	p = makeParser("", "{x: }")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectParserError(t, "(1:5) unexpected symbol", expr, err)
+
+	p = makeParser("", "{x: 1, x: 2}")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectParserError(t, "(1:8) duplicate field 'x'", expr, err)
+
+	p = makeParser("", "{x: 1")
+	loadGrammar(p)
+	expr, err = parseStruct(p)
+	expectParserError(t, "(1:5) expected right brace", expr, err)
+}
+
 func TestParseAccessExpr(t *testing.T) {
 	good := func(source string, exp string) {
 		t.Helper()
@@ -768,7 +1117,18 @@ func TestParseAssignExpr(t *testing.T) {
 	p = makeParser("", "foo() := 123")
 	loadGrammar(p)
 	expr, err = parseExpr(p, precLowest)
-	expectParserError(t, "(1:1) left hand must be an identifier", expr, err)
+	expectParserError(t, "(1:1) left hand must be an identifier or a field", expr, err)
+
+	p = makeParser("", "a.b := 123")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(= (a).b 123)", expr, err)
+	expectStart(t, expr, 1, 1)
+
+	p = makeParser("", "a.b.c := 1 + 2")
+	loadGrammar(p)
+	expr, err = parseExpr(p, precLowest)
+	expectNoParserErrors(t, "(= ((a).b).c (+ 1 2))", expr, err)
 
 	p = makeParser("", "a :=")
 	loadGrammar(p)
@@ -885,6 +1245,23 @@ func TestParseNumber(t *testing.T) {
 	expectParserError(t, "(1:1) malformed number literal", expr, err)
 }
 
+func TestParseFloat(t *testing.T) {
+	parser := makeParser("", "1.25")
+
+	expr, err := parseFloat(parser)
+	expectNoParserErrors(t, "1.25", expr, err)
+	expectStart(t, expr, 1, 1)
+
+	parser = makeParser("", "abc")
+
+	expr, err = parseFloat(parser)
+	expectParserError(t, "(1:1) expected float literal", expr, err)
+
+	loc := Loc{Line: 1, Col: 1}
+	expr, err = evalFloat(parser, token{Type: tokFloat, Lexeme: "abc", Loc: loc})
+	expectParserError(t, "(1:1) malformed float literal", expr, err)
+}
+
 func TestParseString(t *testing.T) {
 	p := makeParser("", `"foo"`)
 	expr, err := parseString(p)
@@ -924,6 +1301,17 @@ func TestParseBoolean(t *testing.T) {
 	expectParserError(t, "(1:1) malformed boolean literal", expr, err)
 }
 
+func TestParseNone(t *testing.T) {
+	p := makeParser("", "none")
+	expr, err := parseNone(p)
+	expectNoParserErrors(t, "none", expr, err)
+	expectStart(t, expr, 1, 1)
+
+	p = makeParser("", "nil")
+	expr, err = parseNone(p)
+	expectParserError(t, "(1:1) expected none", expr, err)
+}
+
 type typeNoteParser func(p *parser) (TypeNote, error)
 
 func expectTypeNote(t *testing.T, fn typeNoteParser, source string, ast string) {
@@ -0,0 +1,863 @@
+package lang
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"plaid/lang/types"
+	"sort"
+)
+
+// A .plaidc file holds a compiled module along with every virtual module it
+// depends on so it can be run without parsing or checking any source code.
+// The file starts with a magic string and a format version followed by the
+// modules, dependencies first and the main module last. Native dependencies
+// are only referred to by name and have to be provided by the host when the
+// file is loaded
+//
+// Every integer is written as a varint, strings are prefixed by their length
+// and floats are written as their IEEE 754 bits
+const plaidcMagic = "plaidc"
+
+// PlaidcVersion is the version of the .plaidc format written by this package.
+// Files written in any other version are rejected
+const PlaidcVersion = 2
+
+// Opcodes identify instructions in a .plaidc file. New instructions must only
+// ever be added at the end so existing files keep their meaning
+const (
+	opHalt byte = iota
+	opNOP
+	opJump
+	opJumpTrue
+	opJumpFalse
+	opPush
+	opPop
+	opCopy
+	opReserve
+	opStore
+	opLoadAttr
+	opLoadSelf
+	opLoadMod
+	opLoad
+	opDispatch
+	opCreateList
+	opCreateStruct
+	opCreateVariant
+	opJumpNotVariant
+	opUnpack
+	opEnterBlock
+	opLeaveBlock
+	opCast
+	opIsType
+	opTypeof
+	opStoreAttr
+	opSubscript
+	opCreateClosure
+	opNone
+	opReturn
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opRem
+	opNeg
+	opNot
+	opEquals
+	opNotEquals
+	opLT
+	opLTEquals
+	opGT
+	opGTEquals
+)
+
+// Tags identify the kind of a constant or a type in a .plaidc file
+const (
+	objNone byte = iota
+	objInt
+	objFloat
+	objStr
+	objBool
+	objFunction
+)
+
+const (
+	typeAny byte = iota
+	typeVoid
+	typeNone
+	typeIdent
+	typeList
+	typeOptional
+	typeTuple
+	typeFunction
+	typeStruct
+	typeUnion
+	typeVar
+)
+
+const (
+	depNative byte = iota
+	depVirtual
+)
+
+// WriteFile writes a module and its virtual dependencies to a .plaidc file
+func WriteFile(path string, mod Module) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = Encode(file, mod); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadFile reads a .plaidc file and returns the main module, ready to be run.
+// Native dependencies are looked up by name in stdlib
+func LoadFile(path string, stdlib map[string]Module) (*ModuleVirtual, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file, stdlib)
+}
+
+// Encode writes a module and its virtual dependencies in the .plaidc format.
+// The module has to be compiled first
+func Encode(w io.Writer, mod Module) error {
+	root, ok := mod.(*ModuleVirtual)
+	if ok == false {
+		return fmt.Errorf("cannot encode native module '%s'", mod.Identifier())
+	}
+
+	enc := &encoder{w: bufio.NewWriter(w), modules: make(map[*ModuleVirtual]int)}
+	enc.bytes([]byte(plaidcMagic))
+	enc.uint(PlaidcVersion)
+
+	order := orderModules(root, nil)
+	enc.uint(uint64(len(order)))
+	for _, mod := range order {
+		enc.module(mod)
+	}
+
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+// orderModules lists a module's virtual dependencies before the module itself
+func orderModules(mod *ModuleVirtual, order []*ModuleVirtual) []*ModuleVirtual {
+	for _, existing := range order {
+		if existing == mod {
+			return order
+		}
+	}
+
+	for _, dep := range mod.dependencies {
+		if dep, ok := dep.module.(*ModuleVirtual); ok {
+			order = orderModules(dep, order)
+		}
+	}
+	return append(order, mod)
+}
+
+type encoder struct {
+	w       *bufio.Writer
+	modules map[*ModuleVirtual]int
+	err     error
+}
+
+func (e *encoder) bytes(buf []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(buf)
+	}
+}
+
+func (e *encoder) byte(b byte) {
+	e.bytes([]byte{b})
+}
+
+func (e *encoder) uint(n uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	e.bytes(buf[:binary.PutUvarint(buf, n)])
+}
+
+func (e *encoder) int(n int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	e.bytes(buf[:binary.PutVarint(buf, n)])
+}
+
+func (e *encoder) string(str string) {
+	e.uint(uint64(len(str)))
+	e.bytes([]byte(str))
+}
+
+func (e *encoder) strings(strs []string) {
+	e.uint(uint64(len(strs)))
+	for _, str := range strs {
+		e.string(str)
+	}
+}
+
+func (e *encoder) fail(format string, args ...interface{}) {
+	if e.err == nil {
+		e.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (e *encoder) module(mod *ModuleVirtual) {
+	if mod.bytecode == nil {
+		e.fail("module '%s' has not been compiled", mod.path)
+		return
+	}
+
+	e.modules[mod] = len(e.modules)
+	e.string(mod.path)
+
+	// Exports are stored with their slot in the module's frame since the
+	// loaded module won't have a scope to look the slots up in
+	e.uint(uint64(len(mod.exports.Fields)))
+	for _, field := range mod.exports.Fields {
+		index, _ := slotIndex(mod.scope, field.Name)
+		e.string(field.Name)
+		e.typ(field.Type)
+		e.uint(uint64(index))
+	}
+
+	var names []string
+	for name := range mod.exportedTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e.uint(uint64(len(names)))
+	for _, name := range names {
+		e.string(name)
+		e.typ(mod.exportedTypes[name])
+	}
+
+	e.uint(uint64(len(mod.dependencies)))
+	for _, dep := range mod.dependencies {
+		e.string(dep.alias)
+		e.string(dep.relative)
+		if virtual, ok := dep.module.(*ModuleVirtual); ok {
+			e.byte(depVirtual)
+			e.uint(uint64(e.modules[virtual]))
+		} else {
+			e.byte(depNative)
+			e.string(dep.module.Identifier())
+		}
+	}
+
+	e.bytecode(*mod.bytecode)
+}
+
+func (e *encoder) bytecode(blob Bytecode) {
+	e.uint(uint64(len(blob.Instructions)))
+	for _, instr := range blob.Instructions {
+		e.instr(instr)
+	}
+
+	for addr := range blob.Instructions {
+		pos, _ := blob.Position(Address(addr))
+		e.string(pos.Module)
+		e.uint(uint64(pos.Loc.Line))
+		e.uint(uint64(pos.Loc.Col))
+	}
+}
+
+func (e *encoder) instr(instr Instr) {
+	switch instr := instr.(type) {
+	case InstrHalt:
+		e.byte(opHalt)
+	case InstrNOP:
+		e.byte(opNOP)
+	case InstrJump:
+		e.byte(opJump)
+		e.uint(uint64(instr.addr))
+	case InstrJumpTrue:
+		e.byte(opJumpTrue)
+		e.uint(uint64(instr.addr))
+	case InstrJumpFalse:
+		e.byte(opJumpFalse)
+		e.uint(uint64(instr.addr))
+	case InstrPush:
+		e.byte(opPush)
+		e.object(instr.Val)
+	case InstrPop:
+		e.byte(opPop)
+	case InstrCopy:
+		e.byte(opCopy)
+	case InstrReserve:
+		e.byte(opReserve)
+		e.string(instr.Name)
+	case InstrStore:
+		e.byte(opStore)
+		e.string(instr.Name)
+		e.int(int64(instr.Depth))
+		e.int(int64(instr.Index))
+	case InstrLoadAttr:
+		e.byte(opLoadAttr)
+		e.string(instr.Name)
+	case InstrLoadSelf:
+		e.byte(opLoadSelf)
+	case InstrLoadMod:
+		e.byte(opLoadMod)
+	case InstrLoad:
+		e.byte(opLoad)
+		e.string(instr.Name)
+		e.int(int64(instr.Depth))
+		e.int(int64(instr.Index))
+	case InstrDispatch:
+		e.byte(opDispatch)
+		e.uint(uint64(instr.args))
+	case InstrCreateList:
+		e.byte(opCreateList)
+		e.uint(uint64(instr.length))
+	case InstrCreateStruct:
+		e.byte(opCreateStruct)
+		e.strings(instr.names)
+	case InstrCreateVariant:
+		e.byte(opCreateVariant)
+		e.string(instr.Enum)
+		e.string(instr.Tag)
+		e.uint(uint64(instr.Arity))
+	case InstrJumpNotVariant:
+		e.byte(opJumpNotVariant)
+		e.string(instr.Tag)
+		e.uint(uint64(instr.addr))
+	case InstrUnpack:
+		e.byte(opUnpack)
+		e.uint(uint64(instr.Arity))
+	case InstrEnterBlock:
+		e.byte(opEnterBlock)
+	case InstrLeaveBlock:
+		e.byte(opLeaveBlock)
+	case InstrCast:
+		e.byte(opCast)
+		e.typ(instr.Type)
+	case InstrIsType:
+		e.byte(opIsType)
+		e.typ(instr.Type)
+	case InstrTypeof:
+		e.byte(opTypeof)
+	case InstrStoreAttr:
+		e.byte(opStoreAttr)
+		e.string(instr.Name)
+	case InstrSubscript:
+		e.byte(opSubscript)
+	case InstrCreateClosure:
+		e.byte(opCreateClosure)
+	case InstrNone:
+		e.byte(opNone)
+	case InstrReturn:
+		e.byte(opReturn)
+	case InstrAdd:
+		e.byte(opAdd)
+	case InstrSub:
+		e.byte(opSub)
+	case InstrMul:
+		e.byte(opMul)
+	case InstrDiv:
+		e.byte(opDiv)
+	case InstrRem:
+		e.byte(opRem)
+	case InstrNeg:
+		e.byte(opNeg)
+	case InstrNot:
+		e.byte(opNot)
+	case InstrEquals:
+		e.byte(opEquals)
+	case InstrNotEquals:
+		e.byte(opNotEquals)
+	case InstrLT:
+		e.byte(opLT)
+	case InstrLTEquals:
+		e.byte(opLTEquals)
+	case InstrGT:
+		e.byte(opGT)
+	case InstrGTEquals:
+		e.byte(opGTEquals)
+	default:
+		e.fail("cannot encode %T instructions", instr)
+	}
+}
+
+func (e *encoder) object(obj Object) {
+	switch obj := obj.(type) {
+	case ObjectNone, *ObjectNone:
+		e.byte(objNone)
+	case *ObjectInt:
+		e.byte(objInt)
+		e.int(obj.val)
+	case *ObjectFloat:
+		e.byte(objFloat)
+		e.uint(math.Float64bits(obj.val))
+	case *ObjectStr:
+		e.byte(objStr)
+		e.string(obj.val)
+	case *ObjectBool:
+		e.byte(objBool)
+		if obj.val {
+			e.byte(1)
+		} else {
+			e.byte(0)
+		}
+	case *ObjectFunction:
+		e.byte(objFunction)
+		e.string(obj.name)
+		e.strings(obj.params)
+		e.bytecode(obj.bytecode)
+	default:
+		e.fail("cannot encode %T constants", obj)
+	}
+}
+
+func (e *encoder) typ(typ types.Type) {
+	switch typ := typ.(type) {
+	case types.Any:
+		e.byte(typeAny)
+	case types.Void:
+		e.byte(typeVoid)
+	case types.None:
+		e.byte(typeNone)
+	case types.Ident:
+		e.byte(typeIdent)
+		e.string(typ.Name)
+	case types.List:
+		e.byte(typeList)
+		e.typ(typ.Child)
+	case types.Optional:
+		e.byte(typeOptional)
+		e.typ(typ.Child)
+	case types.Tuple:
+		e.byte(typeTuple)
+		e.types(typ.Children)
+	case types.Function:
+		e.byte(typeFunction)
+		e.types(typ.Params.Children)
+		e.typ(typ.Ret)
+		e.uint(uint64(len(typ.TypeParams)))
+		for _, param := range typ.TypeParams {
+			e.string(param.Name)
+		}
+	case types.Struct:
+		e.byte(typeStruct)
+		e.uint(uint64(len(typ.Fields)))
+		for _, field := range typ.Fields {
+			e.string(field.Name)
+			e.typ(field.Type)
+		}
+	case types.Union:
+		e.byte(typeUnion)
+		e.string(typ.Name)
+		e.uint(uint64(len(typ.Variants)))
+		for _, variant := range typ.Variants {
+			e.string(variant.Name)
+			e.types(variant.Payload)
+		}
+	case types.Var:
+		e.byte(typeVar)
+		e.string(typ.Name)
+	default:
+		e.fail("cannot encode type %s", typ)
+	}
+}
+
+func (e *encoder) types(typs []types.Type) {
+	e.uint(uint64(len(typs)))
+	for _, typ := range typs {
+		e.typ(typ)
+	}
+}
+
+// Decode reads modules in the .plaidc format and returns the main module,
+// ready to be run. Native dependencies are looked up by name in stdlib and the
+// bytecode of every module is verified before it's returned
+func Decode(r io.Reader, stdlib map[string]Module) (*ModuleVirtual, error) {
+	dec := &decoder{r: bufio.NewReader(r), stdlib: stdlib}
+
+	magic := make([]byte, len(plaidcMagic))
+	if _, err := io.ReadFull(dec.r, magic); err != nil || string(magic) != plaidcMagic {
+		return nil, fmt.Errorf("not a plaidc file")
+	}
+
+	if version := dec.uint(); dec.err == nil && version != PlaidcVersion {
+		return nil, fmt.Errorf("unsupported plaidc version %d", version)
+	}
+
+	count := dec.length()
+	for i := 0; i < count && dec.err == nil; i++ {
+		dec.modules = append(dec.modules, dec.module())
+	}
+
+	if dec.err != nil {
+		return nil, dec.err
+	} else if len(dec.modules) == 0 {
+		return nil, fmt.Errorf("plaidc file contains no modules")
+	}
+
+	// The VM trusts its input so nothing gets loaded that could crash it
+	for _, mod := range dec.modules {
+		if err := verifyModule(mod); err != nil {
+			return nil, err
+		}
+	}
+
+	return dec.modules[len(dec.modules)-1], nil
+}
+
+type decoder struct {
+	r       *bufio.Reader
+	stdlib  map[string]Module
+	modules []*ModuleVirtual
+	err     error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.fail("unexpected end of plaidc file")
+	}
+	return b
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail("unexpected end of plaidc file")
+	}
+	return n
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	n, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.fail("unexpected end of plaidc file")
+	}
+	return n
+}
+
+// length reads the size of a list or a string
+func (d *decoder) length() int {
+	n := d.uint()
+	if n > math.MaxInt32 {
+		d.fail("malformed plaidc file")
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if d.err != nil {
+		return ""
+	}
+
+	// Read in chunks so a corrupt length can't allocate more memory than
+	// the file actually contains
+	var buf []byte
+	for len(buf) < n && d.err == nil {
+		chunk := make([]byte, n-len(buf))
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		if _, err := io.ReadFull(d.r, chunk); err != nil {
+			d.fail("unexpected end of plaidc file")
+		}
+		buf = append(buf, chunk...)
+	}
+	return string(buf)
+}
+
+func (d *decoder) strings() []string {
+	var strs []string
+	count := d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		strs = append(strs, d.string())
+	}
+	return strs
+}
+
+func (d *decoder) module() *ModuleVirtual {
+	mod := &ModuleVirtual{path: d.string()}
+
+	// Exported variables are found through the slots stored with them
+	mod.scope = makeScope(nil)
+	mod.scope.Module = mod
+	mod.scope.slots = make(map[string]int)
+
+	count := d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		name := d.string()
+		mod.AddExport(name, d.typ())
+		mod.scope.slots[name] = int(d.uint())
+	}
+
+	count = d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		name := d.string()
+		mod.AddExportedType(name, d.typ())
+	}
+
+	count = d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		alias := d.string()
+		relative := d.string()
+		if dep := d.dependency(); dep != nil {
+			mod.link(alias, relative, dep)
+		}
+	}
+
+	blob := d.bytecode()
+	mod.bytecode = &blob
+	return mod
+}
+
+func (d *decoder) dependency() Module {
+	switch kind := d.byte(); kind {
+	case depNative:
+		name := d.string()
+		if dep, ok := d.stdlib[name]; ok {
+			return dep
+		}
+		d.fail("unknown native module '%s'", name)
+	case depVirtual:
+		index := d.uint()
+		if index < uint64(len(d.modules)) {
+			return d.modules[index]
+		}
+		d.fail("unknown module %d", index)
+	default:
+		d.fail("unknown dependency kind %d", kind)
+	}
+	return nil
+}
+
+func (d *decoder) bytecode() (blob Bytecode) {
+	count := d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		blob.write(d.instr())
+	}
+
+	for addr := 0; addr < count && d.err == nil; addr++ {
+		module := d.string()
+		line := int(d.uint())
+		col := int(d.uint())
+		blob.Positions[addr] = Position{module, Loc{line, col}}
+	}
+	return blob
+}
+
+func (d *decoder) instr() Instr {
+	switch op := d.byte(); op {
+	case opHalt:
+		return InstrHalt{}
+	case opNOP:
+		return InstrNOP{}
+	case opJump:
+		return InstrJump{d.address()}
+	case opJumpTrue:
+		return InstrJumpTrue{d.address()}
+	case opJumpFalse:
+		return InstrJumpFalse{d.address()}
+	case opPush:
+		return InstrPush{d.object()}
+	case opPop:
+		return InstrPop{}
+	case opCopy:
+		return InstrCopy{}
+	case opReserve:
+		return InstrReserve{d.string()}
+	case opStore:
+		return InstrStore{d.string(), int(d.int()), int(d.int())}
+	case opLoadAttr:
+		return InstrLoadAttr{d.string()}
+	case opLoadSelf:
+		return InstrLoadSelf{}
+	case opLoadMod:
+		return InstrLoadMod{}
+	case opLoad:
+		return InstrLoad{d.string(), int(d.int()), int(d.int())}
+	case opDispatch:
+		return InstrDispatch{d.length()}
+	case opCreateList:
+		return InstrCreateList{d.length()}
+	case opCreateStruct:
+		return InstrCreateStruct{d.strings()}
+	case opCreateVariant:
+		return InstrCreateVariant{d.string(), d.string(), d.length()}
+	case opJumpNotVariant:
+		return InstrJumpNotVariant{d.string(), d.address()}
+	case opUnpack:
+		return InstrUnpack{d.length()}
+	case opEnterBlock:
+		return InstrEnterBlock{}
+	case opLeaveBlock:
+		return InstrLeaveBlock{}
+	case opCast:
+		return InstrCast{d.typ()}
+	case opIsType:
+		return InstrIsType{d.typ()}
+	case opTypeof:
+		return InstrTypeof{}
+	case opStoreAttr:
+		return InstrStoreAttr{d.string()}
+	case opSubscript:
+		return InstrSubscript{}
+	case opCreateClosure:
+		return InstrCreateClosure{}
+	case opNone:
+		return InstrNone{}
+	case opReturn:
+		return InstrReturn{}
+	case opAdd:
+		return InstrAdd{}
+	case opSub:
+		return InstrSub{}
+	case opMul:
+		return InstrMul{}
+	case opDiv:
+		return InstrDiv{}
+	case opRem:
+		return InstrRem{}
+	case opNeg:
+		return InstrNeg{}
+	case opNot:
+		return InstrNot{}
+	case opEquals:
+		return InstrEquals{}
+	case opNotEquals:
+		return InstrNotEquals{}
+	case opLT:
+		return InstrLT{}
+	case opLTEquals:
+		return InstrLTEquals{}
+	case opGT:
+		return InstrGT{}
+	case opGTEquals:
+		return InstrGTEquals{}
+	default:
+		d.fail("unknown opcode %d", op)
+		return InstrNOP{}
+	}
+}
+
+func (d *decoder) address() Address {
+	addr := d.uint()
+	if addr > math.MaxUint32 {
+		d.fail("malformed plaidc file")
+	}
+	return Address(addr)
+}
+
+func (d *decoder) object() Object {
+	switch tag := d.byte(); tag {
+	case objNone:
+		return &ObjectNone{}
+	case objInt:
+		return &ObjectInt{d.int()}
+	case objFloat:
+		return &ObjectFloat{math.Float64frombits(d.uint())}
+	case objStr:
+		return &ObjectStr{d.string()}
+	case objBool:
+		return &ObjectBool{d.byte() != 0}
+	case objFunction:
+		name := d.string()
+		params := d.strings()
+		return &ObjectFunction{name: name, params: params, bytecode: d.bytecode()}
+	default:
+		d.fail("unknown constant kind %d", tag)
+		return &ObjectNone{}
+	}
+}
+
+func (d *decoder) typ() types.Type {
+	switch tag := d.byte(); tag {
+	case typeAny:
+		return types.Any{}
+	case typeVoid:
+		return types.Void{}
+	case typeNone:
+		return types.None{}
+	case typeIdent:
+		return types.Ident{Name: d.string()}
+	case typeList:
+		return types.List{Child: d.typ()}
+	case typeOptional:
+		return types.Optional{Child: d.typ()}
+	case typeTuple:
+		return types.Tuple{Children: d.types()}
+	case typeFunction:
+		params := d.types()
+		ret := d.typ()
+		var vars []types.Var
+		count := d.length()
+		for i := 0; i < count && d.err == nil; i++ {
+			vars = append(vars, types.Var{Name: d.string()})
+		}
+		return types.Function{Params: types.Tuple{Children: params}, Ret: ret, TypeParams: vars}
+	case typeStruct:
+		var fields []struct {
+			Name string
+			Type types.Type
+		}
+		count := d.length()
+		for i := 0; i < count && d.err == nil; i++ {
+			name := d.string()
+			fields = append(fields, struct {
+				Name string
+				Type types.Type
+			}{name, d.typ()})
+		}
+		return types.Struct{Fields: fields}
+	case typeUnion:
+		union := types.Union{Name: d.string()}
+		count := d.length()
+		for i := 0; i < count && d.err == nil; i++ {
+			name := d.string()
+			union.Variants = append(union.Variants, types.Variant{Name: name, Payload: d.types()})
+		}
+		return union
+	case typeVar:
+		return types.Var{Name: d.string()}
+	default:
+		d.fail("unknown type kind %d", tag)
+		return types.Any{}
+	}
+}
+
+func (d *decoder) types() []types.Type {
+	var typs []types.Type
+	count := d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		typs = append(typs, d.typ())
+	}
+	return typs
+}
@@ -0,0 +1,205 @@
+package lang
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plaid/lang/types"
+	"strings"
+	"testing"
+)
+
+func TestEncodeInstructions(t *testing.T) {
+	fn := &ObjectFunction{name: "f", params: []string{"a"}, bytecode: Bytecode{}}
+	fn.bytecode.write(InstrLoad{"a", 0, 0})
+	fn.bytecode.write(InstrReturn{})
+	fn.bytecode.locate("main.plaid", Loc{1, 2})
+
+	// Everything after the first halt is unreachable so the verifier accepts
+	// the instructions even though they wouldn't make sense when run
+	blob := Bytecode{}
+	for _, instr := range []Instr{
+		InstrHalt{},
+		InstrNOP{},
+		InstrJump{1},
+		InstrJumpTrue{2},
+		InstrJumpFalse{3},
+		InstrPush{&ObjectNone{}},
+		InstrPush{&ObjectInt{-42}},
+		InstrPush{&ObjectFloat{1.5}},
+		InstrPush{&ObjectStr{"abc"}},
+		InstrPush{&ObjectBool{true}},
+		InstrPush{fn},
+		InstrPop{},
+		InstrCopy{},
+		InstrReserve{"a"},
+		InstrStore{"a", -1, 2},
+		InstrLoadAttr{"b"},
+		InstrLoadSelf{},
+		InstrLoadMod{},
+		InstrLoad{"c", 3, 4},
+		InstrDispatch{2},
+		InstrCreateList{3},
+		InstrCreateStruct{[]string{"x", "y"}},
+		InstrCreateVariant{"Shape", "Rect", 2},
+		InstrJumpNotVariant{"Rect", 5},
+		InstrUnpack{2},
+		InstrEnterBlock{},
+		InstrLeaveBlock{},
+		InstrCast{types.Optional{Child: types.List{Child: types.BuiltinInt}}},
+		InstrIsType{types.Struct{Fields: []struct {
+			Name string
+			Type types.Type
+		}{{"x", types.Any{}}, {"y", types.None{}}}}},
+		InstrTypeof{},
+		InstrStoreAttr{"d"},
+		InstrSubscript{},
+		InstrCreateClosure{},
+		InstrNone{},
+		InstrReturn{},
+		InstrAdd{},
+		InstrSub{},
+		InstrMul{},
+		InstrDiv{},
+		InstrRem{},
+		InstrNeg{},
+		InstrNot{},
+		InstrEquals{},
+		InstrNotEquals{},
+		InstrLT{},
+		InstrLTEquals{},
+		InstrGT{},
+		InstrGTEquals{},
+		InstrHalt{},
+	} {
+		blob.write(instr)
+	}
+	blob.locate("main.plaid", Loc{3, 4})
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, &ModuleVirtual{path: "main.plaid", bytecode: &blob}); err != nil {
+		t.Fatal(err)
+	}
+
+	mod, err := Decode(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, mod.bytecode.Disassemble(), blob.Disassemble())
+	for addr, instr := range mod.bytecode.Instructions {
+		if push, ok := instr.(InstrPush); ok {
+			if decoded, ok := push.Val.(*ObjectFunction); ok {
+				expectString(t, decoded.name, "f")
+				expectString(t, strings.Join(decoded.params, " "), "a")
+				expectString(t, decoded.bytecode.Disassemble(), fn.bytecode.Disassemble())
+			} else if push.Val.Equals(blob.Instructions[addr].(InstrPush).Val) == false {
+				t.Errorf("Expected %s, got %s", blob.Instructions[addr], instr)
+			}
+		}
+	}
+}
+
+func TestEncodeTypes(t *testing.T) {
+	shape := types.Union{Name: "Shape", Variants: []types.Variant{
+		{Name: "Empty"},
+		{Name: "Rect", Payload: []types.Type{types.BuiltinInt, types.BuiltinFloat}},
+	}}
+	generic := types.Function{
+		Params:     types.Tuple{Children: []types.Type{types.Var{Name: "T"}}},
+		Ret:        types.Tuple{Children: []types.Type{types.Var{Name: "T"}, types.Void{}}},
+		TypeParams: []types.Var{{Name: "T"}},
+	}
+
+	mod := &ModuleVirtual{path: "main.plaid", scope: makeScope(nil), bytecode: &Bytecode{}}
+	mod.bytecode.write(InstrHalt{})
+	mod.scope.slots = map[string]int{"id": 1}
+	mod.AddExport("id", generic)
+	mod.AddExportedType("Shape", shape)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Decode(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, loaded.String(), mod.String())
+	expectBool(t, loaded.Exports().Fields[0].Type.Equals(generic), true)
+	expectBool(t, loaded.ExportedTypes()["Shape"].Equals(shape), true)
+	index, _ := slotIndex(loaded.scope, "id")
+	expectSame(t, index, 1)
+}
+
+func TestEncodeModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plaidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "util.plaid"), []byte(`
+		let factor := 3;
+		pub let scale := fn (n: Int): Int { return n * factor; };
+		pub enum Shape { Empty Square(Int) };`), 0644)
+
+	var out []string
+	stdlib := map[string]Module{"io": makePrintLibrary(&out)}
+	mod := compileProgram(t, filepath.Join(dir, "main.plaid"), `
+		use "io";
+		use "util.plaid";
+		let area := fn (s: util.Shape): Int {
+			return match s {
+				Empty => 0,
+				Square(n) => n * n,
+			};
+		};
+		io.print(util.scale(2));
+		io.print(area(util.Shape.Square(util.scale(1))));
+		io.print(1.5 as Float);`, stdlib, OptBasic)
+
+	path := filepath.Join(dir, "main.plaidc")
+	if err := WriteFile(path, mod); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFile(path, stdlib)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, loaded.Identifier(), mod.Identifier())
+	expectSame(t, len(loaded.Dependencies()), 2)
+	if err := Run(loaded); err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, strings.Join(out, " "), "6 9 1.5")
+
+	// Native modules have to be provided by the host
+	_, err = LoadFile(path, nil)
+	expectAnError(t, err, "unknown native module 'io'")
+
+	// Modules have to be compiled before they're encoded
+	err = Encode(ioutil.Discard, &ModuleVirtual{path: "main.plaid"})
+	expectAnError(t, err, "module 'main.plaid' has not been compiled")
+}
+
+func TestDecodeErrors(t *testing.T) {
+	expectDecodeError := func(data string, exp string) {
+		t.Helper()
+		_, err := Decode(strings.NewReader(data), nil)
+		expectAnError(t, err, exp)
+	}
+
+	expectDecodeError("", "not a plaidc file")
+	expectDecodeError("plaid\x01", "not a plaidc file")
+	expectDecodeError("plaidc\x01", "unsupported plaidc version 1")
+	expectDecodeError("plaidc\x02\x00", "plaidc file contains no modules")
+	expectDecodeError("plaidc\x02\x01\x04main", "unexpected end of plaidc file")
+	expectDecodeError("plaidc\x02\x01\x00\x00\x00\x00\x01\xff", "unknown opcode 255")
+}
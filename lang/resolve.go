@@ -0,0 +1,102 @@
+package lang
+
+import (
+	"sort"
+)
+
+// Every variable is stored in a slot of the runtime frame that belongs to
+// the scope declaring the variable. Modules and functions always get a frame,
+// blocks only get a frame if they declare variables. A variable is resolved
+// to the number of frames between the scope using the variable and the scope
+// declaring it plus the variable's slot in that frame. Which scope declares a
+// variable is decided by the checker since a block can use a variable from
+// outside of the block before declaring its own variable with the same name
+
+// hasFrame returns true if the scope gets its own frame at runtime
+func hasFrame(s *Scope) bool {
+	return s.block == false || len(s.Local) > 0
+}
+
+// slotNames lists the variables stored in the scope's frame in slot order. A
+// function's parameters come first in the order they are passed, the aliases
+// of a module's dependencies come last
+func slotNames(s *Scope) []string {
+	names := append([]string{}, s.params...)
+
+	var locals []string
+	for name := range s.Local {
+		if isParam(s, name) == false {
+			locals = append(locals, name)
+		}
+	}
+	sort.Strings(locals)
+	names = append(names, locals...)
+
+	if s.Parent == nil && s.Module != nil {
+		for _, dep := range s.Module.dependencies {
+			names = append(names, dep.alias)
+		}
+	}
+
+	return names
+}
+
+func isParam(s *Scope, name string) bool {
+	for _, param := range s.params {
+		if param == name {
+			return true
+		}
+	}
+	return false
+}
+
+// slotIndex returns the slot of a variable in the scope's own frame
+func slotIndex(s *Scope, name string) (int, bool) {
+	if s.slots == nil {
+		s.slots = make(map[string]int)
+		for i, name := range slotNames(s) {
+			if _, exists := s.slots[name]; exists == false {
+				s.slots[name] = i
+			}
+		}
+	}
+
+	index, ok := s.slots[name]
+	return index, ok
+}
+
+// resolveVariable finds the frame depth and slot of a variable declared by
+// the given scope
+func resolveVariable(s *Scope, decl *Scope, name string) (depth int, index int, ok bool) {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope == decl {
+			index, ok := slotIndex(scope, name)
+			return depth, index, ok
+		}
+
+		if hasFrame(scope) {
+			depth++
+		}
+	}
+
+	return 0, 0, false
+}
+
+// compileLoad pushes the value of a variable declared by decl onto the stack
+func compileLoad(s *Scope, decl *Scope, name string) InstrLoad {
+	depth, index, ok := resolveVariable(s, decl, name)
+	if ok == false {
+		depth = -1
+	}
+	return InstrLoad{name, depth, index}
+}
+
+// compileStore pops a value off the stack and stores it in a variable
+// declared by decl
+func compileStore(s *Scope, decl *Scope, name string) InstrStore {
+	depth, index, ok := resolveVariable(s, decl, name)
+	if ok == false {
+		depth = -1
+	}
+	return InstrStore{name, depth, index}
+}
@@ -5,12 +5,22 @@ import (
 )
 
 type Scope struct {
+	block    bool
+	loop     bool
 	Module   *ModuleVirtual
 	Parent   *Scope
 	Children map[ASTNode]*Scope
 	Local    map[string]types.Type
+	Types    map[string]types.Type
 	Self     types.Function
 	Errors   []error
+	narrowed map[string]types.Type
+	consts   map[string]Expr
+	literals map[*IdentExpr]Expr
+	targets  map[Expr]types.Type
+	params   []string
+	slots    map[string]int
+	bindings map[*IdentExpr]*Scope
 }
 
 func makeScope(parent *Scope) *Scope {
@@ -18,6 +28,12 @@ func makeScope(parent *Scope) *Scope {
 		Parent:   parent,
 		Children: make(map[ASTNode]*Scope),
 		Local:    make(map[string]types.Type),
+		Types:    make(map[string]types.Type),
+		narrowed: make(map[string]types.Type),
+		consts:   make(map[string]Expr),
+		literals: make(map[*IdentExpr]Expr),
+		targets:  make(map[Expr]types.Type),
+		bindings: make(map[*IdentExpr]*Scope),
 	}
 
 	if parent != nil {
@@ -27,6 +43,28 @@ func makeScope(parent *Scope) *Scope {
 	return scope
 }
 
+// makeBlockScope creates a scope for a block nested inside of another scope.
+// Unlike a function's scope, a block's scope shares the Self type of the
+// scope around it
+func makeBlockScope(parent *Scope) *Scope {
+	scope := makeScope(parent)
+	scope.Self = parent.Self
+	scope.block = true
+	return scope
+}
+
+// inFunction returns true if the scope belongs to a function body or to a
+// block nested inside of a function body
+func (s *Scope) inFunction() bool {
+	for scope := s; scope.Parent != nil; scope = scope.Parent {
+		if scope.block == false {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *Scope) HasLocal(name string) bool {
 	if _, ok := s.Local[name]; ok {
 		return true
@@ -36,16 +74,111 @@ func (s *Scope) HasLocal(name string) bool {
 
 func (s *Scope) AddLocal(name string, typ types.Type) {
 	s.Local[name] = typ
+	delete(s.consts, name)
+}
+
+// AddConst declares a read-only variable. If the variable was initialized
+// with a literal, that literal is kept so uses of the variable can be inlined
+func (s *Scope) AddConst(name string, typ types.Type, literal Expr) {
+	s.Local[name] = typ
+	s.consts[name] = literal
+}
+
+// IsConst returns true if the name refers to a read-only variable
+func (s *Scope) IsConst(name string) bool {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.HasLocal(name) {
+			_, ok := scope.consts[name]
+			return ok
+		}
+	}
+
+	return false
+}
+
+// lookupConstLiteral returns the literal a read-only variable was initialized
+// with or nil if the variable is mutable or its value isn't a literal
+func (s *Scope) lookupConstLiteral(name string) Expr {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.HasLocal(name) {
+			return scope.consts[name]
+		}
+	}
+
+	return nil
 }
 
+// lookupBinding returns the scope declaring the closest variable with the
+// given name or nil if there is no such variable. The aliases of a module's
+// dependencies belong to the module's root scope
+func (s *Scope) lookupBinding(name string) *Scope {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.HasLocal(name) || (scope.Parent == nil && scope.lookupDependency(name) != nil) {
+			return scope
+		}
+	}
+
+	return nil
+}
+
+// bind records which scope declares the variable an identifier refers to and
+// the literal the variable was initialized with if it's a constant. Because
+// the checker visits statements in order, an identifier used before a block
+// declares a variable with the same name stays bound to the variable declared
+// outside of the block
+func (s *Scope) bind(expr *IdentExpr) {
+	if scope := s.lookupBinding(expr.Name); scope != nil {
+		s.bindings[expr] = scope
+	}
+
+	if literal := s.lookupConstLiteral(expr.Name); literal != nil {
+		s.literals[expr] = literal
+	}
+}
+
+// binding returns the scope declaring the variable an identifier refers to
+func (s *Scope) binding(expr *IdentExpr) *Scope {
+	if scope, ok := s.bindings[expr]; ok {
+		return scope
+	}
+
+	return s.lookupBinding(expr.Name)
+}
+
+// Lookup returns the type of a variable, taking into account any narrowing
+// from the conditions that enclose the current position in the program
 func (s *Scope) Lookup(name string) types.Type {
+	if typ, ok := s.narrowed[name]; ok {
+		return typ
+	}
+
 	if s.HasLocal(name) {
 		return s.Local[name]
 	}
 
 	if s.Parent != nil {
 		return s.Parent.Lookup(name)
-	} else if s.Module != nil {
+	}
+
+	return s.lookupDependency(name)
+}
+
+// LookupDeclared returns the type a variable was declared with, ignoring any
+// narrowing
+func (s *Scope) LookupDeclared(name string) types.Type {
+	if s.HasLocal(name) {
+		return s.Local[name]
+	}
+
+	if s.Parent != nil {
+		return s.Parent.LookupDeclared(name)
+	}
+
+	return s.lookupDependency(name)
+}
+
+func (s *Scope) lookupDependency(name string) types.Type {
+	if s.Module != nil {
 		for _, dep := range s.Module.dependencies {
 			if dep.alias == name {
 				return dep.module.Exports()
@@ -56,6 +189,95 @@ func (s *Scope) Lookup(name string) types.Type {
 	return nil
 }
 
+// AddType binds a name to a type in the type namespace of this scope
+func (s *Scope) AddType(name string, typ types.Type) {
+	s.Types[name] = typ
+}
+
+// LookupType returns the type bound to a name by a type declaration
+func (s *Scope) LookupType(name string) types.Type {
+	if typ, ok := s.Types[name]; ok {
+		return typ
+	}
+
+	if s.Parent != nil {
+		return s.Parent.LookupType(name)
+	}
+
+	return nil
+}
+
+// TypeNames returns the names of every type declared in this scope or any
+// of its ancestors
+func (s *Scope) TypeNames() (names []string) {
+	for scope := s; scope != nil; scope = scope.Parent {
+		for name := range scope.Types {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// LookupModuleTypes returns the types exported by the imported module with
+// the given alias. The boolean is false if no module has that alias
+func (s *Scope) LookupModuleTypes(alias string) (map[string]types.Type, bool) {
+	if s.Module != nil {
+		for _, dep := range s.Module.dependencies {
+			if dep.alias == alias {
+				return dep.module.ExportedTypes(), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// IsModule returns true if the name refers to an imported module instead of
+// a variable
+func (s *Scope) IsModule(name string) bool {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.HasLocal(name) {
+			return false
+		}
+	}
+
+	return s.lookupDependency(name) != nil
+}
+
+// narrow overrides the types of the given variables until the returned
+// function is called
+func (s *Scope) narrow(narrowed map[string]types.Type) (restore func()) {
+	saved := make(map[string]types.Type)
+	for name, typ := range narrowed {
+		if old, ok := s.narrowed[name]; ok {
+			saved[name] = old
+		}
+		s.narrowed[name] = typ
+	}
+
+	return func() {
+		for name := range narrowed {
+			if old, ok := saved[name]; ok {
+				s.narrowed[name] = old
+			} else {
+				delete(s.narrowed, name)
+			}
+		}
+	}
+}
+
+// widen removes any narrowing of a variable between the current scope and
+// the scope that declared the variable
+func (s *Scope) widen(name string) {
+	for scope := s; scope != nil; scope = scope.Parent {
+		delete(scope.narrowed, name)
+		if scope.HasLocal(name) {
+			return
+		}
+	}
+}
+
 func (s *Scope) AllErrors() []error {
 	errs := s.Errors
 	for _, scope := range s.Children {
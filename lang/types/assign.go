@@ -0,0 +1,77 @@
+package types
+
+// AssignableTo returns true if a value of type `from` can be used wherever a
+// value of type `to` is expected. Every type except Void is assignable to Any,
+// none and any value assignable to the child type are assignable to an
+// optional type. Functions are contravariant in their parameters and
+// covariant in their return type. Lists and tuples can't be modified so they
+// are covariant in their elements. Struct fields can be reassigned so structs
+// must match exactly
+func AssignableTo(from Type, to Type) bool {
+	if from.IsError() || to.IsError() {
+		return false
+	}
+
+	switch to := to.(type) {
+	case Any:
+		_, isVoid := from.(Void)
+		return isVoid == false
+	case Optional:
+		switch from := from.(type) {
+		case None:
+			return true
+		case Optional:
+			return AssignableTo(from.Child, to.Child)
+		default:
+			return AssignableTo(from, to.Child)
+		}
+	case Function:
+		if from, ok := from.(Function); ok {
+			return functionAssignableTo(from, to)
+		}
+		return false
+	case Tuple:
+		if from, ok := from.(Tuple); ok {
+			return allAssignableTo(from.Children, to.Children)
+		}
+		return false
+	case List:
+		if from, ok := from.(List); ok {
+			return AssignableTo(from.Child, to.Child)
+		}
+		return false
+	}
+
+	return to.Equals(from)
+}
+
+func functionAssignableTo(from Function, to Function) bool {
+	// Generic signatures are only interchangeable if they are identical
+	if len(from.TypeParams) > 0 || len(to.TypeParams) > 0 {
+		return to.Equals(from)
+	}
+
+	if allAssignableTo(to.Params.Children, from.Params.Children) == false {
+		return false
+	}
+
+	if _, ok := to.Ret.(Void); ok {
+		return (Void{}).Equals(from.Ret)
+	}
+
+	return AssignableTo(from.Ret, to.Ret)
+}
+
+func allAssignableTo(from []Type, to []Type) bool {
+	if len(from) != len(to) {
+		return false
+	}
+
+	for i, typ := range from {
+		if AssignableTo(typ, to[i]) == false {
+			return false
+		}
+	}
+
+	return true
+}
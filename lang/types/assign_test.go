@@ -0,0 +1,61 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestAssignableTo(t *testing.T) {
+	expectAssignable := func(from Type, to Type, exp bool) {
+		t.Helper()
+		if AssignableTo(from, to) != exp {
+			t.Errorf("Expected AssignableTo(%s, %s) to be %t", from, to, exp)
+		}
+	}
+
+	expectAssignable(tInt, tInt, true)
+	expectAssignable(tInt, tBool, false)
+	expectAssignable(tError, tError, false)
+	expectAssignable(tInt, tError, false)
+
+	// Any
+	expectAssignable(tInt, tAny, true)
+	expectAssignable(tFunc, tAny, true)
+	expectAssignable(Void{}, tAny, false)
+	expectAssignable(tAny, tInt, false)
+
+	// Optionals
+	expectAssignable(tBool, tOpt, true)
+	expectAssignable(None{}, tOpt, true)
+	expectAssignable(tOpt, tOpt, true)
+	expectAssignable(tOpt, tBool, false)
+	expectAssignable(tInt, tOpt, false)
+	expectAssignable(tOpt, Optional{tAny}, true)
+
+	// Lists and tuples
+	expectAssignable(tList, List{tAny}, true)
+	expectAssignable(List{tAny}, tList, false)
+	expectAssignable(List{tInt}, List{Optional{tInt}}, true)
+	expectAssignable(Tuple{[]Type{tInt, tBool}}, Tuple{[]Type{tAny, tOpt}}, true)
+	expectAssignable(Tuple{[]Type{tInt}}, Tuple{[]Type{tInt, tInt}}, false)
+
+	// Functions
+	intToInt := Function{Tuple{[]Type{tInt}}, tInt, nil}
+	intToAny := Function{Tuple{[]Type{tInt}}, tAny, nil}
+	anyToInt := Function{Tuple{[]Type{tAny}}, tInt, nil}
+	intToVoid := Function{Tuple{[]Type{tInt}}, Void{}, nil}
+	expectAssignable(intToInt, intToAny, true)
+	expectAssignable(intToAny, intToInt, false)
+	expectAssignable(anyToInt, intToInt, true)
+	expectAssignable(intToInt, anyToInt, false)
+	expectAssignable(intToInt, intToVoid, false)
+	expectAssignable(intToVoid, intToVoid, true)
+	expectAssignable(intToVoid, intToAny, false)
+
+	generic := Function{Tuple{[]Type{tVarT}}, tVarT, []Var{tVarT}}
+	expectAssignable(generic, generic, true)
+	expectAssignable(generic, intToInt, false)
+
+	// Structs must match exactly
+	expectAssignable(tStruct, tStruct, true)
+	expectAssignable(tStruct, Struct{}, false)
+}
@@ -0,0 +1,136 @@
+package types
+
+// Var describes a type parameter of a generic function
+type Var struct {
+	Name string
+}
+
+// Equals returns true if the other type is a type variable with the same name
+func (t Var) Equals(other Type) bool {
+	if t2, ok := other.(Var); ok {
+		return t.Name == t2.Name
+	}
+
+	return false
+}
+
+// IsError returns false because this is a properly resolved type
+func (t Var) IsError() bool  { return false }
+func (t Var) String() string { return t.Name }
+func (t Var) isType()        {}
+
+// Bindings maps the names of type variables to the types they stand for
+type Bindings map[string]Type
+
+// Unify matches a type that may contain the given type variables against a
+// type with no free variables. Whenever a variable lines up with part of the
+// other type, that part is recorded in the bindings unless the variable was
+// already bound. Unify never fails, mismatches are left for the caller to
+// detect by substituting the bindings and comparing the result
+func Unify(pattern Type, typ Type, vars []Var, bindings Bindings) {
+	switch pattern := pattern.(type) {
+	case Var:
+		if isVar(pattern, vars) == false {
+			return
+		}
+
+		if _, bound := bindings[pattern.Name]; bound {
+			return
+		}
+
+		switch typ.(type) {
+		case None, Error:
+			return
+		default:
+			bindings[pattern.Name] = typ
+		}
+	case Optional:
+		if opt, ok := typ.(Optional); ok {
+			Unify(pattern.Child, opt.Child, vars, bindings)
+		} else {
+			Unify(pattern.Child, typ, vars, bindings)
+		}
+	case List:
+		if typ, ok := typ.(List); ok {
+			Unify(pattern.Child, typ.Child, vars, bindings)
+		}
+	case Tuple:
+		if typ, ok := typ.(Tuple); ok && len(pattern.Children) == len(typ.Children) {
+			for i, child := range pattern.Children {
+				Unify(child, typ.Children[i], vars, bindings)
+			}
+		}
+	case Struct:
+		if typ, ok := typ.(Struct); ok {
+			for _, field := range pattern.Fields {
+				if member := typ.Member(field.Name); member != nil {
+					Unify(field.Type, member, vars, bindings)
+				}
+			}
+		}
+	case Function:
+		if typ, ok := typ.(Function); ok && len(typ.TypeParams) == 0 {
+			Unify(pattern.Params, typ.Params, vars, bindings)
+			Unify(pattern.Ret, typ.Ret, vars, bindings)
+		}
+	}
+}
+
+// Substitute replaces every bound type variable in a type with the type it
+// is bound to
+func Substitute(typ Type, bindings Bindings) Type {
+	switch typ := typ.(type) {
+	case Var:
+		if bound, ok := bindings[typ.Name]; ok {
+			return bound
+		}
+		return typ
+	case Optional:
+		return Optional{Child: Substitute(typ.Child, bindings)}
+	case List:
+		return List{Child: Substitute(typ.Child, bindings)}
+	case Tuple:
+		children := []Type{}
+		for _, child := range typ.Children {
+			children = append(children, Substitute(child, bindings))
+		}
+		return Tuple{Children: children}
+	case Struct:
+		out := Struct{}
+		for _, field := range typ.Fields {
+			out.Fields = append(out.Fields, struct {
+				Name string
+				Type Type
+			}{field.Name, Substitute(field.Type, bindings)})
+		}
+		return out
+	case Function:
+		// Variables declared by a nested generic function shadow the bindings
+		inner := bindings
+		if len(typ.TypeParams) > 0 {
+			inner = Bindings{}
+			for name, bound := range bindings {
+				if isVar(Var{name}, typ.TypeParams) == false {
+					inner[name] = bound
+				}
+			}
+		}
+
+		return Function{
+			Params:     Substitute(typ.Params, inner).(Tuple),
+			Ret:        Substitute(typ.Ret, inner),
+			TypeParams: typ.TypeParams,
+		}
+	default:
+		return typ
+	}
+}
+
+func isVar(v Var, vars []Var) bool {
+	for _, v2 := range vars {
+		if v.Equals(v2) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"testing"
+)
+
+var tVarT = Var{"T"}
+var tVarU = Var{"U"}
+
+func TestTypeVar(t *testing.T) {
+	expectEquivalentType(t, tVarT, Var{"T"})
+	expectNotEquivalentType(t, tVarT, tVarU)
+	expectNotEquivalentType(t, tVarT, tInt)
+
+	expectString(t, tVarT.String(), "T")
+	expectBool(t, tVarT.IsError(), false)
+	tVarT.isType()
+}
+
+func TestTypeGenericFunction(t *testing.T) {
+	fn := Function{Tuple{[]Type{tVarT}}, tVarT, []Var{tVarT}}
+	expectEquivalentType(t, fn, fn)
+	expectNotEquivalentType(t, fn, Function{Tuple{[]Type{tVarT}}, tVarT, nil})
+	expectNotEquivalentType(t, fn, Function{Tuple{[]Type{tVarU}}, tVarU, []Var{tVarU}})
+	expectString(t, fn.String(), "<T>(T) => T")
+
+	fn = Function{Tuple{[]Type{tVarT, tVarU}}, tVarU, []Var{tVarT, tVarU}}
+	expectString(t, fn.String(), "<T U>(T U) => U")
+}
+
+func TestUnify(t *testing.T) {
+	vars := []Var{tVarT, tVarU}
+	expectBindings := func(pattern Type, typ Type, exp Bindings) {
+		t.Helper()
+		got := Bindings{}
+		Unify(pattern, typ, vars, got)
+		if len(got) != len(exp) {
+			t.Fatalf("Expected %d bindings, got %d", len(exp), len(got))
+		}
+		for name, typ := range exp {
+			expectEquivalentType(t, got[name], typ)
+		}
+	}
+
+	expectBindings(tVarT, tInt, Bindings{"T": tInt})
+	expectBindings(tVarT, tOpt, Bindings{"T": tOpt})
+	expectBindings(tVarT, None{}, Bindings{})
+	expectBindings(Var{"X"}, tInt, Bindings{})
+	expectBindings(Optional{tVarT}, tOpt, Bindings{"T": tBool})
+	expectBindings(Optional{tVarT}, tInt, Bindings{"T": tInt})
+	expectBindings(List{tVarT}, tList, Bindings{"T": tInt})
+	expectBindings(List{tVarT}, tInt, Bindings{})
+	expectBindings(Tuple{[]Type{tVarT, tVarU}}, Tuple{[]Type{tInt, tBool}}, Bindings{"T": tInt, "U": tBool})
+	expectBindings(Tuple{[]Type{tVarT, tVarU}}, Tuple{[]Type{tInt}}, Bindings{})
+	expectBindings(Tuple{[]Type{tVarT, tVarT}}, Tuple{[]Type{tInt, tBool}}, Bindings{"T": tInt})
+	expectBindings(Function{Tuple{[]Type{tVarT}}, tVarU, nil}, Function{Tuple{[]Type{tInt}}, tBool, nil},
+		Bindings{"T": tInt, "U": tBool})
+	expectBindings(Function{Tuple{[]Type{tVarT}}, tVarU, nil}, Function{Tuple{[]Type{tVarT}}, tVarT, []Var{tVarT}},
+		Bindings{})
+	expectBindings(Struct{[]struct {
+		Name string
+		Type Type
+	}{{"x", tVarT}}}, tStruct, Bindings{"T": tInt})
+}
+
+func TestSubstitute(t *testing.T) {
+	bindings := Bindings{"T": tInt, "U": tBool}
+	expectEquivalentType(t, Substitute(tVarT, bindings), tInt)
+	expectEquivalentType(t, Substitute(Var{"X"}, bindings), Var{"X"})
+	expectEquivalentType(t, Substitute(Optional{tVarU}, bindings), tOpt)
+	expectEquivalentType(t, Substitute(List{tVarT}, bindings), tList)
+	expectEquivalentType(t, Substitute(Tuple{[]Type{tVarT, tVarU}}, bindings), Tuple{[]Type{tInt, tBool}})
+	expectEquivalentType(t, Substitute(Struct{[]struct {
+		Name string
+		Type Type
+	}{{"x", tVarT}}}, bindings), Struct{[]struct {
+		Name string
+		Type Type
+	}{{"x", tInt}}})
+	expectEquivalentType(t,
+		Substitute(Function{Tuple{[]Type{tVarT}}, tVarU, nil}, bindings),
+		Function{Tuple{[]Type{tInt}}, tBool, nil})
+	expectEquivalentType(t,
+		Substitute(Function{Tuple{[]Type{tVarT}}, tVarU, []Var{tVarT}}, bindings),
+		Function{Tuple{[]Type{tVarT}}, tBool, []Var{tVarT}})
+	expectEquivalentType(t, Substitute(tInt, bindings), tInt)
+}
@@ -3,6 +3,9 @@ package types
 // BuiltinInt is the canonical integer type symbol
 var BuiltinInt = Ident{"Int"}
 
+// BuiltinFloat is the canonical floating-point type symbol
+var BuiltinFloat = Ident{"Float"}
+
 // BuiltinStr is the canonical string type symbol
 var BuiltinStr = Ident{"Str"}
 
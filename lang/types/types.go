@@ -35,15 +35,17 @@ func (t Struct) String() (out string) {
 	return "{" + out + "}"
 }
 
+// Equals returns true if another struct has the same field names with
+// identical types. The order of the fields doesn't matter
 func (t Struct) Equals(other Type) bool {
 	if t2, ok := other.(Struct); ok {
 		if len(t.Fields) != len(t2.Fields) {
 			return false
 		}
 
-		for i, field := range t.Fields {
-			field2 := t2.Fields[i]
-			if field.Name != field2.Name || field.Type.Equals(field2.Type) == false {
+		for _, field := range t.Fields {
+			typ2 := t2.Member(field.Name)
+			if typ2 == nil || field.Type.Equals(typ2) == false {
 				return false
 			}
 		}
@@ -121,15 +123,28 @@ func (t Void) IsError() bool  { return false }
 func (t Void) String() string { return "Void" }
 func (t Void) isType()        {}
 
-// Function describes mappings of 0+ parameter types to a return type
+// Function describes mappings of 0+ parameter types to a return type. A
+// generic function lists the type variables used by its signature in
+// TypeParams
 type Function struct {
-	Params Tuple
-	Ret    Type
+	Params     Tuple
+	Ret        Type
+	TypeParams []Var
 }
 
 // Equals returns true if another type has an identical structure and identical names
 func (t Function) Equals(other Type) bool {
 	if t2, ok := other.(Function); ok {
+		if len(t.TypeParams) != len(t2.TypeParams) {
+			return false
+		}
+
+		for i, param := range t.TypeParams {
+			if param.Equals(t2.TypeParams[i]) == false {
+				return false
+			}
+		}
+
 		return t.Params.Equals(t2.Params) && t.Ret.Equals(t2.Ret)
 	}
 
@@ -137,9 +152,20 @@ func (t Function) Equals(other Type) bool {
 }
 
 // IsError returns false because this is a properly resolved type
-func (t Function) IsError() bool  { return false }
-func (t Function) String() string { return fmt.Sprintf("%s => %s", t.Params, t.Ret) }
-func (t Function) isType()        {}
+func (t Function) IsError() bool { return false }
+func (t Function) isType()       {}
+
+func (t Function) String() string {
+	if len(t.TypeParams) > 0 {
+		var vars []Type
+		for _, param := range t.TypeParams {
+			vars = append(vars, param)
+		}
+		return fmt.Sprintf("<%s>%s => %s", concatTypes(vars), t.Params, t.Ret)
+	}
+
+	return fmt.Sprintf("%s => %s", t.Params, t.Ret)
+}
 
 // Tuple describes a group of types
 type Tuple struct {
@@ -209,6 +235,80 @@ func (t Optional) IsError() bool  { return false }
 func (t Optional) String() string { return fmt.Sprintf("%s?", t.Child) }
 func (t Optional) isType()        {}
 
+// Union describes a named type whose values are exactly one of several
+// variants. Each variant can carry a fixed list of values
+type Union struct {
+	Name     string
+	Variants []Variant
+}
+
+// Variant describes one of the alternatives of a union type
+type Variant struct {
+	Name    string
+	Payload []Type
+}
+
+// Equals returns true if another union has the same name and the same
+// variants in the same order
+func (t Union) Equals(other Type) bool {
+	if t2, ok := other.(Union); ok {
+		if t.Name != t2.Name || len(t.Variants) != len(t2.Variants) {
+			return false
+		}
+
+		for i, variant := range t.Variants {
+			variant2 := t2.Variants[i]
+			if variant.Name != variant2.Name || len(variant.Payload) != len(variant2.Payload) {
+				return false
+			}
+
+			for j, typ := range variant.Payload {
+				if typ.Equals(variant2.Payload[j]) == false {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// IsError returns false because this is a properly resolved type
+func (t Union) IsError() bool  { return false }
+func (t Union) String() string { return t.Name }
+func (t Union) isType()        {}
+
+// Variant returns the variant with the given name if the union has one
+func (t Union) Variant(name string) (Variant, bool) {
+	for _, variant := range t.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+// None is the type of the `none` literal and can be used in place of any
+// optional type
+type None struct{}
+
+// Equals returns true if the other type is also None
+func (t None) Equals(other Type) bool {
+	if _, ok := other.(None); ok {
+		return true
+	}
+
+	return false
+}
+
+// IsError returns false because this is a properly resolved type
+func (t None) IsError() bool  { return false }
+func (t None) String() string { return "None" }
+func (t None) isType()        {}
+
 // Ident describes a type aliased to an identifier
 type Ident struct {
 	Name string
@@ -11,7 +11,7 @@ var tBool = Ident{"Bool"}
 var tOpt = Optional{tBool}
 var tList = List{tInt}
 var tTuple = Tuple{[]Type{tInt, tBool, tOpt, tList}}
-var tFunc = Function{tTuple, tList}
+var tFunc = Function{tTuple, tList, nil}
 var tStruct = Struct{[]struct {
 	Name string
 	Type Type
@@ -36,6 +36,10 @@ func TestTypeStruct(t *testing.T) {
 		Name string
 		Type Type
 	}{{"z", tBool}, {"x", tInt}, {"y", tInt}}})
+	expectEquivalentType(t, tStruct, Struct{[]struct {
+		Name string
+		Type Type
+	}{{"y", tInt}, {"t", tBool}, {"x", tInt}}})
 
 	expectString(t, tStruct.String(), "{t:Bool x:Int y:Int}")
 	expectBool(t, tStruct.IsError(), false)
@@ -81,8 +85,8 @@ func TestTypeFunction(t *testing.T) {
 	expectEquivalentType(t, tFunc, tFunc)
 	expectNotEquivalentType(t, tFunc, tList)
 	expectNotEquivalentType(t, tFunc, tError)
-	expectNotEquivalentType(t, tFunc, Function{tTuple, tBool})
-	expectNotEquivalentType(t, tFunc, Function{Tuple{}, tList})
+	expectNotEquivalentType(t, tFunc, Function{tTuple, tBool, nil})
+	expectNotEquivalentType(t, tFunc, Function{Tuple{}, tList, nil})
 	expectBool(t, tFunc.Equals(tAny), false)
 	expectBool(t, tAny.Equals(tFunc), true)
 
@@ -119,6 +123,27 @@ func TestTypeList(t *testing.T) {
 	tList.isType()
 }
 
+func TestTypeUnion(t *testing.T) {
+	shape := Union{"Shape", []Variant{{"Circle", []Type{tInt}}, {"Empty", nil}}}
+	expectEquivalentType(t, shape, Union{"Shape", []Variant{{"Circle", []Type{tInt}}, {"Empty", nil}}})
+	expectNotEquivalentType(t, shape, Union{"Form", []Variant{{"Circle", []Type{tInt}}, {"Empty", nil}}})
+	expectNotEquivalentType(t, shape, Union{"Shape", []Variant{{"Circle", []Type{tBool}}, {"Empty", nil}}})
+	expectNotEquivalentType(t, shape, Union{"Shape", []Variant{{"Circle", []Type{tInt, tInt}}, {"Empty", nil}}})
+	expectNotEquivalentType(t, shape, Union{"Shape", []Variant{{"Circle", []Type{tInt}}, {"None", nil}}})
+	expectNotEquivalentType(t, shape, Union{"Shape", []Variant{{"Circle", []Type{tInt}}}})
+	expectNotEquivalentType(t, shape, tInt)
+
+	expectString(t, shape.String(), "Shape")
+	expectBool(t, shape.IsError(), false)
+	shape.isType()
+
+	variant, ok := shape.Variant("Circle")
+	expectBool(t, ok, true)
+	expectString(t, variant.Name, "Circle")
+	_, ok = shape.Variant("Square")
+	expectBool(t, ok, false)
+}
+
 func TestTypeOptional(t *testing.T) {
 	expectEquivalentType(t, Optional{tInt}, Optional{tInt})
 	expectNotEquivalentType(t, Optional{tInt}, tInt)
@@ -132,6 +157,17 @@ func TestTypeOptional(t *testing.T) {
 	tOpt.isType()
 }
 
+func TestTypeNone(t *testing.T) {
+	expectEquivalentType(t, None{}, None{})
+	expectNotEquivalentType(t, None{}, tOpt)
+	expectNotEquivalentType(t, None{}, Void{})
+	expectBool(t, tAny.Equals(None{}), true)
+
+	expectString(t, None{}.String(), "None")
+	expectBool(t, None{}.IsError(), false)
+	None{}.isType()
+}
+
 func TestTypeIdent(t *testing.T) {
 	expectEquivalentType(t, tInt, tInt)
 	expectNotEquivalentType(t, tInt, tError)
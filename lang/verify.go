@@ -0,0 +1,213 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verify checks that bytecode can be run without corrupting the VM. It
+// follows every path through the bytecode tracking how many values are on the
+// stack and which variable slots exist at each instruction. Paths that meet
+// at the same instruction have to agree on both. Instructions that can't be
+// reached are never run and aren't checked. The bodies of any functions pushed
+// by the bytecode are verified too
+func Verify(blob Bytecode) error {
+	return verifyBlob(blob, []int{0}, false)
+}
+
+// verifyModule checks a module's bytecode before the VM runs it
+func verifyModule(mod *ModuleVirtual) error {
+	if err := Verify(*mod.bytecode); err != nil {
+		return fmt.Errorf("invalid bytecode in '%s': %s", mod.path, err)
+	}
+	return nil
+}
+
+// verifyState describes the VM before an instruction is run. Frames holds the
+// number of slots in each frame, innermost frame last
+type verifyState struct {
+	stack  int
+	frames []int
+}
+
+func (s verifyState) sameFrames(other verifyState) bool {
+	if len(s.frames) != len(other.frames) {
+		return false
+	}
+
+	for i, slots := range s.frames {
+		if slots != other.frames[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifyBlob checks a module's bytecode or a function's body. The function's
+// own frame is the last of the frames it starts with, the other frames belong
+// to the code around the function
+func verifyBlob(blob Bytecode, frames []int, inFunction bool) error {
+	if len(blob.Instructions) == 0 {
+		return fmt.Errorf("bytecode is empty")
+	}
+
+	states := make([]*verifyState, len(blob.Instructions))
+	states[0] = &verifyState{0, frames}
+	base := len(frames)
+	pending := []Address{0}
+
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		instr := blob.Instructions[addr]
+		state := *states[addr]
+		state.frames = append([]int{}, state.frames...)
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s %s: %s", addr, instrName(instr), fmt.Sprintf(format, args...))
+		}
+
+		pops, pushes, err := stackEffect(instr)
+		if err != nil {
+			return fail("%s", err)
+		} else if state.stack < pops {
+			return fail("stack underflow, needs %d values but has %d", pops, state.stack)
+		}
+		state.stack += pushes - pops
+
+		next := []Address{addr + 1}
+		switch instr := instr.(type) {
+		case InstrHalt:
+			if inFunction {
+				return fail("cannot halt inside of a function")
+			}
+			continue
+		case InstrReturn:
+			if inFunction == false {
+				return fail("cannot return outside of a function")
+			} else if state.stack != 0 {
+				return fail("returns with %d extra values on the stack", state.stack)
+			}
+			continue
+		case InstrLoadSelf:
+			if inFunction == false {
+				return fail("cannot load self outside of a function")
+			}
+		case InstrReserve:
+			state.frames[len(state.frames)-1]++
+		case InstrEnterBlock:
+			state.frames = append(state.frames, 0)
+		case InstrLeaveBlock:
+			if len(state.frames) <= base {
+				return fail("leaves a block that was never entered")
+			}
+			state.frames = state.frames[:len(state.frames)-1]
+		case InstrLoad:
+			if hasSlot(state.frames, instr.Depth, instr.Index) == false {
+				return fail("undefined variable '%s'", instr.Name)
+			}
+		case InstrStore:
+			if hasSlot(state.frames, instr.Depth, instr.Index) == false {
+				return fail("undefined variable '%s'", instr.Name)
+			}
+		case InstrPush:
+			if fn, ok := instr.Val.(*ObjectFunction); ok {
+				// A closure's own frame starts out with its parameters
+				inner := append(append([]int{}, state.frames...), len(fn.params))
+				if err := verifyBlob(fn.bytecode, inner, true); err != nil {
+					return fail("in function %s: %s", functionName(fn), err)
+				}
+			}
+		case InstrJump:
+			next = []Address{instr.addr}
+		}
+
+		if target, ok := jumpTarget(instr); ok {
+			if int(target) >= len(blob.Instructions) {
+				return fail("jumps to %s past the end of the bytecode", target)
+			}
+
+			if _, isJump := instr.(InstrJump); isJump == false {
+				next = append(next, target)
+			}
+		}
+
+		for _, succ := range next {
+			if int(succ) >= len(blob.Instructions) {
+				return fail("runs past the end of the bytecode")
+			}
+
+			if states[succ] == nil {
+				states[succ] = &verifyState{state.stack, state.frames}
+				pending = append(pending, succ)
+			} else if states[succ].stack != state.stack {
+				return fail("reaches %s with %d values on the stack, another path has %d", succ, state.stack, states[succ].stack)
+			} else if states[succ].sameFrames(state) == false {
+				return fail("reaches %s with different variables than another path", succ)
+			}
+		}
+	}
+
+	return nil
+}
+
+// stackEffect returns how many values an instruction pops off the stack and
+// how many values it pushes onto the stack. The return value popped by
+// InstrReturn isn't counted so every function has to leave the stack empty
+// after popping it
+func stackEffect(instr Instr) (pops int, pushes int, err error) {
+	switch instr := instr.(type) {
+	case InstrHalt, InstrNOP, InstrJump, InstrReserve, InstrEnterBlock, InstrLeaveBlock:
+		return 0, 0, nil
+	case InstrPush, InstrLoad, InstrLoadSelf:
+		return 0, 1, nil
+	case InstrPop, InstrStore, InstrJumpTrue, InstrJumpFalse, InstrJumpNotVariant:
+		return 1, 0, nil
+	case InstrReturn:
+		return 1, 0, nil
+	case InstrCopy:
+		return 1, 2, nil
+	case InstrLoadAttr, InstrLoadMod, InstrCast, InstrIsType, InstrTypeof,
+		InstrCreateClosure, InstrNeg, InstrNot:
+		return 1, 1, nil
+	case InstrStoreAttr, InstrSubscript, InstrAdd, InstrSub, InstrMul, InstrDiv,
+		InstrRem, InstrEquals, InstrNotEquals, InstrLT, InstrLTEquals, InstrGT,
+		InstrGTEquals:
+		return 2, 1, nil
+	case InstrDispatch:
+		return instr.args + 1, 1, nil
+	case InstrCreateList:
+		return instr.length, 1, nil
+	case InstrCreateStruct:
+		return len(instr.names), 1, nil
+	case InstrCreateVariant:
+		return instr.Arity, 1, nil
+	case InstrUnpack:
+		return 1, instr.Arity, nil
+	default:
+		return 0, 0, fmt.Errorf("cannot be interpreted")
+	}
+}
+
+// hasSlot returns true if the slot at the given depth and index exists
+func hasSlot(frames []int, depth int, index int) bool {
+	if depth < 0 || depth >= len(frames) {
+		return false
+	}
+	return index >= 0 && index < frames[len(frames)-1-depth]
+}
+
+func instrName(instr Instr) string {
+	if fields := strings.Fields(instr.String()); len(fields) > 0 {
+		return fields[0]
+	}
+	return fmt.Sprintf("%T", instr)
+}
+
+func functionName(fn *ObjectFunction) string {
+	if fn.name == "" {
+		return "<anonymous>"
+	}
+	return fmt.Sprintf("'%s'", fn.name)
+}
@@ -0,0 +1,131 @@
+package lang
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	good := func(instrs ...Instr) {
+		t.Helper()
+		if err := Verify(Bytecode{Instructions: instrs}); err != nil {
+			t.Errorf("Expected no errors, got '%s'", err)
+		}
+	}
+
+	bad := func(exp string, instrs ...Instr) {
+		t.Helper()
+		expectAnError(t, Verify(Bytecode{Instructions: instrs}), exp)
+	}
+
+	fn := func(name string, params []string, instrs ...Instr) *ObjectFunction {
+		return &ObjectFunction{name: name, params: params, bytecode: Bytecode{Instructions: instrs}}
+	}
+
+	good(InstrHalt{})
+	good(
+		InstrReserve{"a"},
+		InstrPush{&ObjectInt{1}},
+		InstrStore{"a", 0, 0},
+		InstrLoad{"a", 0, 0},
+		InstrJumpFalse{6},
+		InstrJump{3},
+		InstrHalt{})
+	good(
+		InstrReserve{"a"},
+		InstrPush{fn("f", []string{"x"},
+			InstrEnterBlock{},
+			InstrReserve{"y"},
+			InstrLoad{"a", 2, 0},
+			InstrLoad{"x", 1, 0},
+			InstrAdd{},
+			InstrStore{"y", 0, 0},
+			InstrLoad{"y", 0, 0},
+			InstrReturn{})},
+		InstrCreateClosure{},
+		InstrStore{"a", 0, 0},
+		InstrHalt{})
+	good(
+		InstrCreateVariant{"Shape", "Empty", 0},
+		InstrCopy{},
+		InstrJumpNotVariant{"Rect", 5},
+		InstrUnpack{2},
+		InstrAdd{},
+		InstrPop{},
+		InstrHalt{})
+
+	bad("bytecode is empty")
+	bad("0x0000 add: stack underflow, needs 2 values but has 0",
+		InstrAdd{}, InstrHalt{})
+	bad("0x0001 call: stack underflow, needs 3 values but has 1",
+		InstrPush{&ObjectInt{1}}, InstrDispatch{2}, InstrHalt{})
+	bad("0x0000 jmp: jumps to 0x0005 past the end of the bytecode",
+		InstrJump{5}, InstrHalt{})
+	bad("0x0000 push: runs past the end of the bytecode",
+		InstrPush{&ObjectInt{1}})
+	bad("0x0002 load: undefined variable 'a'",
+		InstrReserve{"a"}, InstrEnterBlock{}, InstrLoad{"a", 0, 0}, InstrHalt{})
+	bad("0x0002 store: undefined variable 'a'",
+		InstrReserve{"a"}, InstrPush{&ObjectInt{1}}, InstrStore{"a", -1, 0}, InstrHalt{})
+	bad("0x0000 leave: leaves a block that was never entered",
+		InstrLeaveBlock{}, InstrHalt{})
+	bad("0x0000 self: cannot load self outside of a function",
+		InstrLoadSelf{}, InstrHalt{})
+	bad("0x0000 continue: cannot be interpreted",
+		instrPendingContinue{}, InstrHalt{})
+	bad("0x0003 pop: reaches 0x0004 with 0 values on the stack, another path has 1",
+		InstrPush{&ObjectBool{true}},
+		InstrPush{&ObjectInt{1}},
+		InstrJumpTrue{4},
+		InstrPop{},
+		InstrHalt{})
+	bad("0x0003 jmp: reaches 0x0005 with different variables than another path",
+		InstrPush{&ObjectBool{true}},
+		InstrJumpTrue{4},
+		InstrEnterBlock{},
+		InstrJump{5},
+		InstrNOP{},
+		InstrHalt{})
+	bad("0x0000 push: in function 'f': 0x0002 ret: returns with 1 extra values on the stack",
+		InstrPush{fn("f", nil, InstrPush{&ObjectInt{1}}, InstrPush{&ObjectInt{2}}, InstrReturn{})},
+		InstrHalt{})
+	bad("0x0000 push: in function <anonymous>: 0x0000 halt: cannot halt inside of a function",
+		InstrPush{fn("", nil, InstrHalt{})},
+		InstrHalt{})
+	bad("0x0001 ret: cannot return outside of a function",
+		InstrPush{&ObjectInt{1}}, InstrReturn{})
+	bad("0x0000 push: in function 'f': 0x0000 load: undefined variable 'y'",
+		InstrPush{fn("f", []string{"x"}, InstrLoad{"y", 0, 1}, InstrReturn{})},
+		InstrHalt{})
+}
+
+func TestVerifyCompiledCode(t *testing.T) {
+	src := `
+		let total := 0;
+		let add := fn (n: Int): Void { total := total + n; };
+		let i := 0;
+		while i < 5 {
+			if i == 3 { break; };
+			let j := i;
+			add(j);
+			i := i + 1;
+		};`
+
+	for _, level := range []OptLevel{OptNone, OptBasic} {
+		mod := compileProgram(t, "", src, nil, level)
+		if err := Verify(*mod.bytecode); err != nil {
+			t.Errorf("Expected no errors at level %d, got '%s'", level, err)
+		}
+	}
+}
+
+func TestDecodeVerifies(t *testing.T) {
+	var buf bytes.Buffer
+	mod := &ModuleVirtual{path: "main.plaid", bytecode: &Bytecode{Instructions: []Instr{InstrPop{}, InstrHalt{}}}}
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Decode(&buf, nil)
+	expectAnError(t, err, "invalid bytecode in 'main.plaid': 0x0000 pop: stack underflow, needs 1 values but has 0")
+}
@@ -1,34 +1,51 @@
 package lang
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// Run evaluates a compiled module. Bytecode that doesn't pass the verifier is
+// rejected before any of it is run. If the program fails at runtime the
+// evaluation stops and a RuntimeError is returned
+func Run(mod *ModuleVirtual) error {
+	if err := verifyModule(mod); err != nil {
+		return err
+	}
 
-func Run(mod *ModuleVirtual) {
 	env := makeEnvironment(nil)
 	mod.environment = env
-	runBlob(mod, env, *mod.bytecode)
+	_, err := runBlob(mod, env, *mod.bytecode)
+	return err
 }
 
-func loadModuleEnvironment(mod Module) {
+func loadModuleEnvironment(mod Module) error {
 	// If the given module has already been evaluated, do nothing.
 	if mod, ok := mod.(*ModuleVirtual); ok && mod.environment == nil {
-		runVirtualModule(mod)
+		return runVirtualModule(mod)
 	}
+	return nil
 }
 
-func runVirtualModule(mod *ModuleVirtual) {
+func runVirtualModule(mod *ModuleVirtual) error {
 	if mod.bytecode == nil {
-		Compile(mod)
+		Compile(mod, OptNone)
+	}
+
+	if err := verifyModule(mod); err != nil {
+		return err
 	}
 
 	env := makeEnvironment(nil)
 	mod.environment = env
-	runBlob(mod, env, *mod.bytecode)
+	_, err := runBlob(mod, env, *mod.bytecode)
+	return err
 }
 
 type Environment struct {
 	parent *Environment
 	stack  []Object
-	state  map[string]Object
+	slots  []Object
 	self   *ObjectClosure
 }
 
@@ -42,68 +59,152 @@ func (e *Environment) popFromStack() Object {
 	return obj
 }
 
-func (e *Environment) alloc(name string) {
-	e.state[name] = ObjectNone{}
+func (e *Environment) alloc() {
+	e.slots = append(e.slots, ObjectNone{})
 }
 
-func (e *Environment) store(name string, obj Object) {
-	if _, ok := e.state[name]; ok {
-		e.state[name] = obj
-	} else {
-		e.parent.store(name, obj)
+// frame returns the environment the given number of frames above this one or
+// nil if there is no such environment
+func (e *Environment) frame(depth int) *Environment {
+	if depth < 0 {
+		return nil
+	}
+	for ; depth > 0 && e != nil; depth-- {
+		e = e.parent
 	}
+	return e
 }
 
-func (e *Environment) load(name string) Object {
-	if obj, ok := e.state[name]; ok {
-		return obj
-	} else {
-		if e.parent == nil {
-			panic(fmt.Sprintf("cannot find variable '%s'", name))
-		}
-		return e.parent.load(name)
+// store updates a slot in an enclosing frame and returns false if no such
+// slot exists
+func (e *Environment) store(depth int, index int, obj Object) bool {
+	frame := e.frame(depth)
+	if frame == nil || index < 0 || index >= len(frame.slots) {
+		return false
 	}
+	frame.slots[index] = obj
+	return true
+}
+
+// load returns the value of a slot in an enclosing frame and false if no such
+// slot exists
+func (e *Environment) load(depth int, index int) (Object, bool) {
+	frame := e.frame(depth)
+	if frame == nil || index < 0 || index >= len(frame.slots) {
+		return nil, false
+	}
+	return frame.slots[index], true
+}
+
+// enterBlock creates an environment for a nested block. The block shares the
+// value stack and the self reference of the enclosing environment
+func (e *Environment) enterBlock() *Environment {
+	child := makeEnvironment(e)
+	child.self = e.self
+	child.stack = e.stack
+	e.stack = nil
+	return child
+}
+
+// leaveBlock hands the value stack back to the enclosing environment
+func (e *Environment) leaveBlock() *Environment {
+	e.parent.stack = e.stack
+	e.stack = nil
+	return e.parent
 }
 
 func makeEnvironment(parent *Environment) *Environment {
 	return &Environment{
 		parent: parent,
-		state:  make(map[string]Object),
 	}
 }
 
-func runBlob(mod *ModuleVirtual, env *Environment, blob Bytecode) Object {
+func runBlob(mod *ModuleVirtual, env *Environment, blob Bytecode) (Object, error) {
 	var ip uint32 = 0
+	var err error
 	instr := blob.Instructions[ip]
 	for {
-		if _, ok := instr.(InstrHalt); ok {
-			return nil
-		} else if _, ok := instr.(InstrReturn); ok {
-			return env.popFromStack()
+		switch instr.(type) {
+		case InstrHalt:
+			return nil, nil
+		case InstrReturn:
+			return env.popFromStack(), nil
+		case InstrEnterBlock:
+			env = env.enterBlock()
+			ip++
+		case InstrLeaveBlock:
+			env = env.leaveBlock()
+			ip++
+		default:
+			var next uint32
+			if next, err = runInstr(mod, ip, env, instr); err != nil {
+				pos, _ := blob.Position(Address(ip))
+				return nil, addStackFrame(err, mod, env, pos)
+			}
+			ip = next
 		}
-
-		ip = runInstr(mod, ip, env, instr)
 		instr = blob.Instructions[ip]
 	}
 }
 
-func runInstr(mod *ModuleVirtual, ip uint32, env *Environment, instr Instr) uint32 {
+// addStackFrame records the function or module that was being evaluated
+// when a runtime error occurred and the source position the evaluation had
+// reached. The position of the innermost frame is where the error occurred
+func addStackFrame(err error, mod *ModuleVirtual, env *Environment, pos Position) error {
+	rterr, ok := err.(RuntimeError)
+	if ok == false {
+		return err
+	}
+
+	frame := StackFrame{Module: mod.path, Loc: pos.Loc}
+	if pos.Module != "" {
+		frame.Module = pos.Module
+	}
+
+	if len(rterr.Stack) == 0 {
+		rterr.Loc = pos.Loc
+	}
+	if env.self != nil {
+		frame.Function = env.self.name
+		if frame.Function == "" {
+			frame.Function = "<anonymous>"
+		}
+	}
+
+	rterr.Stack = append(rterr.Stack, frame)
+	return rterr
+}
+
+// operandError reports a value that an instruction can't operate on
+func operandError(instr Instr, obj Object) error {
+	name := strings.Fields(instr.String())[0]
+	msg := fmt.Sprintf("cannot use %s as an operand of '%s'", typeName(obj), name)
+	return RuntimeError{Message: msg}
+}
+
+func runInstr(mod *ModuleVirtual, ip uint32, env *Environment, instr Instr) (uint32, error) {
 	switch instr := instr.(type) {
 	case InstrHalt:
-		return ip
+		return ip, nil
 	case InstrNOP:
 		// do nothing
 	case InstrJump:
-		return uint32(instr.addr)
+		return uint32(instr.addr), nil
 	case InstrJumpTrue:
-		a := env.popFromStack().(*ObjectBool)
-		if a.val {
-			return uint32(instr.addr)
+		top := env.popFromStack()
+		a, ok := top.(*ObjectBool)
+		if ok == false {
+			return ip, operandError(instr, top)
+		} else if a.val {
+			return uint32(instr.addr), nil
 		}
 	case InstrJumpFalse:
-		a := env.popFromStack().(*ObjectBool)
-		if a.val == false {
-			return uint32(instr.addr)
+		top := env.popFromStack()
+		a, ok := top.(*ObjectBool)
+		if ok == false {
+			return ip, operandError(instr, top)
+		} else if a.val == false {
+			return uint32(instr.addr), nil
 		}
 	case InstrPush:
 		env.pushToStack(instr.Val)
@@ -114,51 +215,89 @@ func runInstr(mod *ModuleVirtual, ip uint32, env *Environment, instr Instr) uint
 		env.pushToStack(a)
 		env.pushToStack(a)
 	case InstrReserve:
-		env.alloc(instr.Name)
+		env.alloc()
 	case InstrStore:
 		a := env.popFromStack()
-		env.store(instr.Name, a)
+		if env.store(instr.Depth, instr.Index, a) == false {
+			msg := fmt.Sprintf("cannot find variable '%s'", instr.Name)
+			return ip, RuntimeError{Message: msg}
+		}
 	case InstrLoadMod:
-		path := env.popFromStack().(*ObjectStr).val
-		var alias string
+		top := env.popFromStack()
+		a, ok := top.(*ObjectStr)
+		if ok == false {
+			return ip, operandError(instr, top)
+		}
+
+		path := a.val
 		var obj Object
 		for _, dep := range mod.dependencies {
 			if dep.relative == path {
 				if dep.module.IsNative() == false {
-					runVirtualModule(dep.module.(*ModuleVirtual))
+					if err := runVirtualModule(dep.module.(*ModuleVirtual)); err != nil {
+						return ip, err
+					}
 				}
-				alias = dep.alias
 				obj = dep.module.export()
 				break
 			}
 		}
 
 		if obj == nil {
-			panic("could not load dependency")
+			msg := fmt.Sprintf("could not load dependency '%s'", path)
+			return ip, RuntimeError{Message: msg}
 		}
 
-		env.alloc(alias)
-		env.store(alias, obj)
+		env.pushToStack(obj)
 	case InstrLoadAttr:
+		top := env.popFromStack()
+		a, ok := top.(*ObjectStruct)
+		if ok == false {
+			return ip, operandError(instr, top)
+		}
+
+		member := a.Member(instr.Name)
+		if member == nil {
+			msg := fmt.Sprintf("struct has no field '%s'", instr.Name)
+			return ip, RuntimeError{Message: msg}
+		}
+		env.pushToStack(member)
+	case InstrStoreAttr:
 		a := env.popFromStack()
-		env.pushToStack(a.(*ObjectStruct).Member(instr.Name))
+		top := env.popFromStack()
+		obj, ok := top.(*ObjectStruct)
+		if ok == false {
+			return ip, operandError(instr, top)
+		}
+		obj.fields[instr.Name] = a
+		env.pushToStack(a)
 	case InstrLoadSelf:
 		env.pushToStack(env.self)
 	case InstrLoad:
-		a := env.load(instr.Name)
+		a, ok := env.load(instr.Depth, instr.Index)
+		if ok == false {
+			msg := fmt.Sprintf("cannot find variable '%s'", instr.Name)
+			return ip, RuntimeError{Message: msg}
+		}
 		env.pushToStack(a)
 	case InstrDispatch:
 		obj := env.popFromStack()
 		switch fn := obj.(type) {
 		case *ObjectClosure:
+			if instr.args != len(fn.params) {
+				msg := fmt.Sprintf("function expects %d arguments, got %d", len(fn.params), instr.args)
+				return ip, RuntimeError{Message: msg}
+			}
+
 			child := makeEnvironment(fn.context)
 			child.self = fn
-			for _, sym := range fn.params {
-				child.alloc(sym)
-				obj := env.popFromStack()
-				child.store(sym, obj)
+			for range fn.params {
+				child.slots = append(child.slots, env.popFromStack())
+			}
+			ret, err := runBlob(mod, child, fn.bytecode)
+			if err != nil {
+				return ip, err
 			}
-			ret := runBlob(mod, child, fn.bytecode)
 			env.pushToStack(ret)
 		case *ObjectBuiltin:
 			var args []Object
@@ -166,54 +305,247 @@ func runInstr(mod *ModuleVirtual, ip uint32, env *Environment, instr Instr) uint
 				args = append(args, env.popFromStack())
 			}
 			if ret, err := fn.val(args); err != nil {
-				panic(err)
+				return ip, RuntimeError{Message: err.Error()}
 			} else {
 				env.pushToStack(ret)
 			}
 		default:
-			panic(fmt.Sprintf("cannot call %T", obj))
+			msg := fmt.Sprintf("cannot call %s", typeName(obj))
+			return ip, RuntimeError{Message: msg}
+		}
+	case InstrCreateList:
+		elements := make([]Object, instr.length)
+		for i := instr.length - 1; i >= 0; i-- {
+			elements[i] = env.popFromStack()
+		}
+		env.pushToStack(&ObjectList{elements})
+	case InstrCreateStruct:
+		fields := make(map[string]Object)
+		for i := len(instr.names) - 1; i >= 0; i-- {
+			fields[instr.names[i]] = env.popFromStack()
+		}
+		env.pushToStack(&ObjectStruct{fields})
+	case InstrCreateVariant:
+		payload := make([]Object, instr.Arity)
+		for i := instr.Arity - 1; i >= 0; i-- {
+			payload[i] = env.popFromStack()
+		}
+		env.pushToStack(&ObjectVariant{instr.Enum, instr.Tag, payload})
+	case InstrJumpNotVariant:
+		top := env.popFromStack()
+		a, ok := top.(*ObjectVariant)
+		if ok == false {
+			return ip, operandError(instr, top)
+		} else if a.tag != instr.Tag {
+			return uint32(instr.addr), nil
+		}
+	case InstrUnpack:
+		top := env.popFromStack()
+		a, ok := top.(*ObjectVariant)
+		if ok == false {
+			return ip, operandError(instr, top)
+		} else if len(a.payload) != instr.Arity {
+			msg := fmt.Sprintf("cannot unpack %d values from %s", instr.Arity, a)
+			return ip, RuntimeError{Message: msg}
+		}
+
+		for _, obj := range a.payload {
+			env.pushToStack(obj)
+		}
+	case InstrSubscript:
+		top := env.popFromStack()
+		index, ok := top.(*ObjectInt)
+		if ok == false {
+			return ip, operandError(instr, top)
+		}
+
+		switch a := env.popFromStack().(type) {
+		case *ObjectList:
+			env.pushToStack(a.Index(index.val))
+		case *ObjectStr:
+			env.pushToStack(a.Index(index.val))
+		default:
+			return ip, operandError(instr, a)
 		}
 	case InstrCreateClosure:
-		fn := env.popFromStack().(*ObjectFunction)
+		top := env.popFromStack()
+		fn, ok := top.(*ObjectFunction)
+		if ok == false {
+			return ip, operandError(instr, top)
+		}
+
 		clo := &ObjectClosure{
 			context:  env,
+			name:     fn.name,
 			params:   fn.params,
 			bytecode: fn.bytecode,
 		}
 		env.pushToStack(clo)
+	case InstrAdd, InstrSub, InstrMul, InstrDiv, InstrRem:
+		b := env.popFromStack()
+		a := env.popFromStack()
+		obj, err := runArithmetic(instr, a, b)
+		if err != nil {
+			return ip, err
+		}
+		env.pushToStack(obj)
+	case InstrNeg:
+		switch a := env.popFromStack().(type) {
+		case *ObjectInt:
+			env.pushToStack(&ObjectInt{-a.val})
+		case *ObjectFloat:
+			env.pushToStack(&ObjectFloat{-a.val})
+		default:
+			return ip, operandError(instr, a)
+		}
+	case InstrCast:
+		a := env.popFromStack()
+		if hasType(a, instr.Type) {
+			env.pushToStack(a)
+		} else {
+			env.pushToStack(&ObjectNone{})
+		}
+	case InstrIsType:
+		a := env.popFromStack()
+		env.pushToStack(&ObjectBool{hasType(a, instr.Type)})
+	case InstrTypeof:
+		a := env.popFromStack()
+		env.pushToStack(&ObjectStr{typeName(a)})
+	case InstrNot:
+		top := env.popFromStack()
+		a, ok := top.(*ObjectBool)
+		if ok == false {
+			return ip, operandError(instr, top)
+		}
+		env.pushToStack(&ObjectBool{a.val == false})
+	case InstrEquals:
+		b := env.popFromStack()
+		a := env.popFromStack()
+		env.pushToStack(&ObjectBool{a.Equals(b)})
+	case InstrNotEquals:
+		b := env.popFromStack()
+		a := env.popFromStack()
+		env.pushToStack(&ObjectBool{a.Equals(b) == false})
+	case InstrLT, InstrLTEquals, InstrGT, InstrGTEquals:
+		b := env.popFromStack()
+		a := env.popFromStack()
+		obj, err := runComparison(instr, a, b)
+		if err != nil {
+			return ip, err
+		}
+		env.pushToStack(obj)
+	default:
+		msg := fmt.Sprintf("cannot interpret %T instructions", instr)
+		return ip, RuntimeError{Message: msg}
+	}
+
+	return ip + 1, nil
+}
+
+// runArithmetic applies an arithmetic instruction to two numbers of the same
+// type. Adding two strings concatenates them
+func runArithmetic(instr Instr, a Object, b Object) (Object, error) {
+	switch a := a.(type) {
+	case *ObjectInt:
+		if b, ok := b.(*ObjectInt); ok {
+			return runIntArithmetic(instr, a.val, b.val)
+		}
+	case *ObjectFloat:
+		if b, ok := b.(*ObjectFloat); ok {
+			switch instr.(type) {
+			case InstrAdd:
+				return &ObjectFloat{a.val + b.val}, nil
+			case InstrSub:
+				return &ObjectFloat{a.val - b.val}, nil
+			case InstrMul:
+				return &ObjectFloat{a.val * b.val}, nil
+			case InstrDiv:
+				return &ObjectFloat{a.val / b.val}, nil
+			}
+		}
+	case *ObjectStr:
+		if _, isAdd := instr.(InstrAdd); isAdd {
+			if b, ok := b.(*ObjectStr); ok {
+				return &ObjectStr{a.val + b.val}, nil
+			}
+		}
+	}
+
+	if supportsArithmetic(instr, a) {
+		return nil, operandError(instr, b)
+	}
+	return nil, operandError(instr, a)
+}
+
+// supportsArithmetic returns true if the object's type can be an operand of
+// the arithmetic instruction
+func supportsArithmetic(instr Instr, obj Object) bool {
+	switch obj.(type) {
+	case *ObjectInt:
+		return true
+	case *ObjectFloat:
+		_, isRem := instr.(InstrRem)
+		return isRem == false
+	case *ObjectStr:
+		_, isAdd := instr.(InstrAdd)
+		return isAdd
+	}
+	return false
+}
+
+func runIntArithmetic(instr Instr, a int64, b int64) (Object, error) {
+	switch instr.(type) {
 	case InstrAdd:
-		b := env.popFromStack().(*ObjectInt)
-		a := env.popFromStack().(*ObjectInt)
-		sum := a.val + b.val
-		env.pushToStack(&ObjectInt{sum})
+		return &ObjectInt{a + b}, nil
 	case InstrSub:
-		b := env.popFromStack().(*ObjectInt)
-		a := env.popFromStack().(*ObjectInt)
-		sum := a.val - b.val
-		env.pushToStack(&ObjectInt{sum})
-	case InstrLT:
-		b := env.popFromStack().(*ObjectInt)
-		a := env.popFromStack().(*ObjectInt)
-		test := a.val < b.val
-		env.pushToStack(&ObjectBool{test})
-	case InstrLTEquals:
-		b := env.popFromStack().(*ObjectInt)
-		a := env.popFromStack().(*ObjectInt)
-		test := a.val <= b.val
-		env.pushToStack(&ObjectBool{test})
-	case InstrGT:
-		b := env.popFromStack().(*ObjectInt)
-		a := env.popFromStack().(*ObjectInt)
-		test := a.val > b.val
-		env.pushToStack(&ObjectBool{test})
-	case InstrGTEquals:
-		b := env.popFromStack().(*ObjectInt)
-		a := env.popFromStack().(*ObjectInt)
-		test := a.val >= b.val
-		env.pushToStack(&ObjectBool{test})
-	default:
-		panic(fmt.Sprintf("cannot interpret %T instructions", instr))
+		return &ObjectInt{a - b}, nil
+	case InstrMul:
+		return &ObjectInt{a * b}, nil
+	}
+
+	if b == 0 {
+		return nil, RuntimeError{Message: "division by zero"}
+	} else if _, isDiv := instr.(InstrDiv); isDiv {
+		return &ObjectInt{a / b}, nil
 	}
+	return &ObjectInt{a % b}, nil
+}
 
-	return ip + 1
+// runComparison applies an ordering instruction to two numbers of the same
+// type
+func runComparison(instr Instr, a Object, b Object) (Object, error) {
+	switch a := a.(type) {
+	case *ObjectInt:
+		if b, ok := b.(*ObjectInt); ok {
+			switch instr.(type) {
+			case InstrLT:
+				return &ObjectBool{a.val < b.val}, nil
+			case InstrLTEquals:
+				return &ObjectBool{a.val <= b.val}, nil
+			case InstrGT:
+				return &ObjectBool{a.val > b.val}, nil
+			case InstrGTEquals:
+				return &ObjectBool{a.val >= b.val}, nil
+			}
+		}
+	case *ObjectFloat:
+		if b, ok := b.(*ObjectFloat); ok {
+			switch instr.(type) {
+			case InstrLT:
+				return &ObjectBool{a.val < b.val}, nil
+			case InstrLTEquals:
+				return &ObjectBool{a.val <= b.val}, nil
+			case InstrGT:
+				return &ObjectBool{a.val > b.val}, nil
+			case InstrGTEquals:
+				return &ObjectBool{a.val >= b.val}, nil
+			}
+		}
+	}
+
+	switch a.(type) {
+	case *ObjectInt, *ObjectFloat:
+		return nil, operandError(instr, b)
+	}
+	return nil, operandError(instr, a)
 }
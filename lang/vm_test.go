@@ -0,0 +1,697 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunWhileStmt(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		while i < 3 {
+			io.print(i);
+			i := i + 1;
+		};`, "0", "1", "2")
+
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		while i < 10 {
+			i := i + 1;
+			if i > 3 {
+				break;
+			};
+			if i < 2 {
+				continue;
+			};
+			io.print(i);
+		};
+		io.print(i);`, "2", "3", "4")
+
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		while i < 2 {
+			let j := 0;
+			while true {
+				if j > 1 {
+					break;
+				};
+				io.print(i + j);
+				j := j + 1;
+			};
+			i := i + 1;
+		};`, "0", "1", "1", "2")
+
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		while i < 5 {
+			let f := fn (n: Int): Int {
+				while true {
+					return n;
+				};
+			};
+			io.print(f(i));
+			i := i + 2;
+		};`, "0", "2", "4")
+}
+
+func TestRunIfStmt(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let classify := fn (n: Int): Void {
+			if n < 0 {
+				io.print("negative");
+			} else if n < 10 {
+				io.print("small");
+			} else if n < 100 {
+				io.print("medium");
+			} else {
+				io.print("large");
+			};
+		};
+		classify(0 - 5);
+		classify(5);
+		classify(50);
+		classify(500);`, `"negative"`, `"small"`, `"medium"`, `"large"`)
+
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		while i < 4 {
+			if i < 2 {
+				io.print(i);
+			} else {
+				io.print(0 - i);
+			};
+			i := i + 1;
+		};`, "0", "1", "-2", "-3")
+}
+
+func TestRunBlockScopes(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let a := 1;
+		if true {
+			let a := 2;
+			io.print(a);
+		};
+		io.print(a);`, "2", "1")
+
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		let f := fn (): Int { return 0; };
+		let g := f;
+		while i < 2 {
+			let j := i * 10 + 1;
+			if i == 0 {
+				f := fn (): Int { return j; };
+			} else {
+				g := fn (): Int { return j; };
+			};
+			i := i + 1;
+		};
+		io.print(f());
+		io.print(g());`, "1", "11")
+
+	expectOutput(t, `
+		use "io";
+		let i := 0;
+		while i < 5 {
+			let j := i;
+			i := i + 1;
+			if j == 1 {
+				let k := j;
+				continue;
+			};
+			if j == 3 {
+				let k := j;
+				break;
+			};
+			io.print(j);
+		};
+		io.print(i);`, "0", "2", "4")
+
+	expectOutput(t, `
+		use "io";
+		let f := fn (n: Int): Int {
+			if n > 0 {
+				let m := n * 2;
+				return m;
+			};
+			return 0;
+		};
+		io.print(f(2));
+		io.print(f(0));`, "4", "0")
+
+	// A block's variable only shadows an outer variable after it's declared
+	expectOutput(t, `
+		use "io";
+		let x := 1;
+		if true {
+			io.print(x);
+			let x := 2;
+			io.print(x);
+		};`, "1", "2")
+
+	expectOutput(t, `
+		use "io";
+		let x := 1;
+		if true {
+			io.print(x + 1);
+			let x := "s";
+			io.print(x);
+		};`, "2", `"s"`)
+
+	expectOutput(t, `
+		use "io";
+		let x := 1;
+		if true {
+			x := 5;
+			let x := 2;
+		};
+		io.print(x);`, "5")
+
+	expectOutput(t, `
+		use "io";
+		let f := fn (x: Int): Int {
+			if true {
+				let y := x;
+				let x := 9;
+				return y + x;
+			};
+			return 0;
+		};
+		io.print(f(3));`, "12")
+
+	expectOutput(t, `
+		use "io";
+		let x := 1;
+		if true {
+			io.print(x);
+			const x := 2;
+			io.print(x);
+		};`, "1", "2")
+}
+
+func TestRunTypeTests(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let describe := fn (a: Any): Str {
+			if a is Int {
+				return "Int " + (typeof (a + 1));
+			} else if a is [Int] {
+				return "list of Int";
+			} else if a is {x: Int} {
+				return "point";
+			};
+			return typeof a;
+		};
+		io.print(describe(1));
+		io.print(describe([1, 2]));
+		io.print(describe(["a"]));
+		io.print(describe({x: 1}));
+		io.print(describe({x: 1, y: 2}));
+		io.print(describe(none));
+		io.print(describe(1.5));
+		io.print(describe(describe));`,
+		`"Int Int"`, `"list of Int"`, `"List"`, `"point"`, `"Struct"`, `"None"`, `"Float"`, `"Function"`)
+
+	expectOutput(t, `
+		use "io";
+		enum Shape { Empty Circle(Float) };
+		let id := fn (a: Any): Any { return a; };
+		let shape := id(Shape.Circle(1.5));
+		io.print(typeof shape);
+		io.print(shape as Shape);
+		io.print(id(1) as Shape);
+		io.print((id(1) as Int) ?? 0);
+		io.print((id("a") as Int) ?? 0);
+		io.print(id(2) as Int ?? 0);
+		io.print(id("b") as Int ?? 0);`, `"Shape"`, "Circle(1.5)", "<none>", "1", "0", "2", "0")
+}
+
+func TestRunEquality(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		io.print(1 == 1);
+		io.print(1 != 1);
+		io.print("a" == "b");
+		io.print("a" != "b");
+		io.print(true == true);
+		io.print(1 + 2 == 3);
+		io.print(io == io);`, "true", "false", "false", "true", "true", "true", "true")
+
+	expectOutput(t, `
+		use "io";
+		let f := fn (): Void {};
+		let g := fn (): Void {};
+		let h := f;
+		io.print(f == f);
+		io.print(f == g);
+		io.print(f == h);`, "true", "false", "true")
+}
+
+func TestRunLogicalExpr(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		io.print(true && false);
+		io.print(true && true);
+		io.print(false || true);
+		io.print(false || false);
+		io.print(!true);
+		io.print(!(1 < 2) || 2 < 3);`, "false", "true", "true", "false", "false", "true")
+
+	expectOutput(t, `
+		use "io";
+		let t := fn (msg: Str): Bool { io.print(msg); return true; };
+		let f := fn (msg: Str): Bool { io.print(msg); return false; };
+		io.print(f("a") && t("b"));
+		io.print(t("c") || f("d"));
+		io.print(t("e") && f("f"));`, `"a"`, "false", `"c"`, "true", `"e"`, `"f"`, "false")
+}
+
+func TestRunArithmetic(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		io.print(6 * 7);
+		io.print(7 / 2);
+		io.print(-7 / 2);
+		io.print(7 % 3);
+		io.print(-7 % 3);
+		io.print(-(2 + 3));
+		io.print(+4);
+		io.print(1 + 2 * 3 - 4 / 2);`, "42", "3", "-3", "1", "-1", "-5", "4", "5")
+
+	expectRuntimeError(t, `
+		use "io";
+		let zero := 0;
+		io.print(1 / zero);`, "(4:14) runtime error: division by zero")
+
+	expectRuntimeError(t, `
+		use "io";
+		let f := fn (n: Int): Int { return n % 0; };
+		io.print(f(5));`, "(3:40) runtime error: division by zero")
+}
+
+func TestRunRuntimeErrors(t *testing.T) {
+	_, err := runProgram(t, `
+		use "io";
+		let divide := fn (a: Int, b: Int): Int { return a / b; };
+		let average := fn (xs: [Int]): Int { return divide(xs[0] ?? 0, 0); };
+		io.print(average([1, 2]));`)
+
+	rterr, ok := err.(RuntimeError)
+	if ok == false {
+		t.Fatalf("Expected a RuntimeError, got %v", err)
+	}
+
+	expectString(t, rterr.Message, "division by zero")
+	expectString(t, rterr.Loc.String(), "(3:53)")
+	expectString(t, rterr.StackTrace(), "  at divide (3:53)\n  at average (4:47)\n  at (5:12)")
+
+	_, err = runProgram(t, `
+		let f := fn (): Int { return 1 % 0; };
+		let g := fn (h: () => Int): Int { return h(); };
+		g(f);`)
+	expectString(t, err.(RuntimeError).StackTrace(), "  at f (2:34)\n  at g (3:44)\n  at (4:3)")
+
+	_, err = runProgram(t, `
+		let g := fn (h: () => Int): Int { return h(); };
+		g(fn (): Int { return 1 / 0; });`)
+	expectString(t, err.(RuntimeError).StackTrace(), "  at <anonymous> (3:27)\n  at g (2:44)\n  at (3:3)")
+}
+
+func TestRunMalformedBytecode(t *testing.T) {
+	expectBytecodeError := func(exp string, instrs ...Instr) {
+		t.Helper()
+		mod := &ModuleVirtual{path: "test.plaid", bytecode: &Bytecode{Instructions: instrs}}
+		if err := Run(mod); err == nil {
+			t.Errorf("Expected error '%s', got no error", exp)
+		} else if err.Error() != exp {
+			t.Errorf("Expected error '%s', got '%s'", exp, err)
+		}
+	}
+
+	expectBytecodeError("runtime error: cannot use Int as an operand of 'not'",
+		InstrPush{&ObjectInt{1}}, InstrNot{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot use Str as an operand of 'add'",
+		InstrPush{&ObjectInt{1}}, InstrPush{&ObjectStr{"a"}}, InstrAdd{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot use Bool as an operand of 'cmplt'",
+		InstrPush{&ObjectBool{true}}, InstrPush{&ObjectBool{false}}, InstrLT{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot use None as an operand of 'add'",
+		InstrPush{&ObjectNone{}}, InstrPush{&ObjectInt{1}}, InstrAdd{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot use Str as an operand of 'sub'",
+		InstrPush{&ObjectStr{"a"}}, InstrPush{&ObjectInt{1}}, InstrSub{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot use Bool as an operand of 'cmplt'",
+		InstrPush{&ObjectBool{true}}, InstrPush{&ObjectInt{1}}, InstrLT{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot use Str as an operand of 'cmpgt'",
+		InstrPush{&ObjectFloat{1}}, InstrPush{&ObjectStr{"a"}}, InstrGT{}, InstrHalt{})
+	expectBytecodeError("runtime error: cannot call Int",
+		InstrPush{&ObjectInt{1}}, InstrDispatch{0}, InstrHalt{})
+	expectBytecodeError("runtime error: function expects 2 arguments, got 0",
+		InstrPush{&ObjectFunction{params: []string{"a", "b"}, bytecode: Bytecode{Instructions: []Instr{InstrPush{&ObjectNone{}}, InstrReturn{}}}}},
+		InstrCreateClosure{}, InstrDispatch{0}, InstrHalt{})
+	expectBytecodeError("runtime error: struct has no field 'x'",
+		InstrCreateStruct{nil}, InstrLoadAttr{"x"}, InstrHalt{})
+	expectBytecodeError("runtime error: could not load dependency 'io'",
+		InstrPush{&ObjectStr{"io"}}, InstrLoadMod{}, InstrHalt{})
+
+	// Bytecode that would corrupt the VM is rejected before it's run
+	expectBytecodeError("invalid bytecode in 'test.plaid': 0x0000 load: undefined variable 'a'",
+		InstrLoad{"a", 0, 0}, InstrHalt{})
+	expectBytecodeError("invalid bytecode in 'test.plaid': 0x0001 load: undefined variable 'a'",
+		InstrReserve{"a"}, InstrLoad{"a", 1, 0}, InstrHalt{})
+	expectBytecodeError("invalid bytecode in 'test.plaid': 0x0001 store: undefined variable 'a'",
+		InstrPush{&ObjectInt{1}}, InstrStore{"a", -1, 0}, InstrHalt{})
+	expectBytecodeError("invalid bytecode in 'test.plaid': 0x0000 pop: stack underflow, needs 1 values but has 0",
+		InstrPop{}, InstrHalt{})
+}
+
+func TestRunFloatArithmetic(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		io.print(1.5 + 2.25);
+		io.print(10.0 - 0.5);
+		io.print(2.5 * 4.0);
+		io.print(1.0 / 4.0);
+		io.print(-1.5e3);
+		io.print(0.1 < 0.2);
+		io.print(2.0 >= 2.0);
+		io.print(1.5 == 1.5);`, "3.75", "9.5", "10.0", "0.25", "-1500.0", "true", "true", "true")
+}
+
+func TestRunStrConcat(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		io.print("foo" + "bar");`, `"foobar"`)
+}
+
+func TestRunListExpr(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let xs := [10, 20, 30];
+		io.print(xs);
+		io.print(xs[0]);
+		io.print(xs[2]);
+		io.print(xs[3]);
+		io.print(xs[-1]);
+		io.print([[1, 2], [3]][1]);
+		io.print(xs == [10, 20, 30]);`, "[10, 20, 30]", "10", "30", "<none>", "<none>", "[3]", "true")
+
+	expectOutput(t, `
+		use "io";
+		let s := "héllo";
+		io.print(s[1]);
+		io.print(s[4]);
+		io.print(s[5]);`, `"é"`, `"o"`, "<none>")
+}
+
+func TestRunOptionals(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let xs := [1, 2];
+		io.print(xs[0] ?? 10);
+		io.print(xs[5] ?? 10);
+		io.print(xs[5] ?? xs[1] ?? 0);
+		io.print(xs[5] == none);
+		io.print(xs[1] == 2);
+		let x := xs[7];
+		if x != none {
+			io.print(x + 1);
+		} else {
+			io.print("missing");
+		};`, "1", "10", "2", "true", "true", `"missing"`)
+
+	expectOutput(t, `
+		use "io";
+		let get := fn (): Int { io.print("called"); return 3; };
+		io.print([4][0] ?? get());`, "4")
+
+	expectOutput(t, `
+		use "io";
+		let orZero := fn (n: Int?): Int { if n == none { return 0; } else { return n; }; };
+		io.print(orZero(none));
+		io.print(orZero(7));`, "0", "7")
+}
+
+func TestRunStructExpr(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let p := {x: 1, y: {z: "abc"}};
+		io.print(p.x);
+		io.print(p.y.z);
+		p.x := p.x + 10;
+		p.y.z := "def";
+		io.print(p.x);
+		io.print(p.y.z);
+		io.print(p == {y: {z: "def"}, x: 11});
+		let move := fn (q: {x: Int y: {z: Str}}): Void { q.x := 0; };
+		move(p);
+		io.print(p.x);`, "1", `"abc"`, "11", `"def"`, "true", "0")
+}
+
+func TestRunTypeStmt(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		type Point := {x: Int y: Int};
+		let origin := fn (): Point { return {x: 0, y: 0}; };
+		let p := origin();
+		p.y := 5;
+		io.print(p.y);`, "5")
+}
+
+func TestRunAnnotatedDeclaration(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let a: Int? := none;
+		io.print(a ?? 1);
+		a := 2;
+		io.print(a ?? 1);
+		let b: [Str] := [];
+		io.print(b);`, "1", "2", "[]")
+}
+
+func TestRunConstDeclaration(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		const limit := 3;
+		const greeting := "hi " + "there";
+		let f := fn (n: Int): Int { return n * limit; };
+		io.print(f(2));
+		io.print(greeting);`, "6", `"hi there"`)
+
+	// Uses of a constant initialized with a literal are inlined
+	ast, _ := ParseString("const a := 5; let b := a;")
+	mod, _ := Link("", ast, nil)
+	Check(mod)
+	btc := Compile(mod, OptNone)
+	for _, instr := range btc.Instructions {
+		if load, ok := instr.(InstrLoad); ok && load.Name == "a" {
+			t.Errorf("Expected constant 'a' to be inlined")
+		}
+	}
+}
+
+func TestCompilePositions(t *testing.T) {
+	ast, _ := ParseString("let a := 1;\nlet b := a + 2;")
+	mod, _ := Link("main.plaid", ast, nil)
+	Check(mod)
+	btc := Compile(mod, OptNone)
+
+	var got []string
+	for addr, instr := range btc.Instructions {
+		if pos, ok := btc.Position(Address(addr)); ok {
+			got = append(got, fmt.Sprintf("%s %s", instr, pos))
+		}
+	}
+
+	expectString(t, strings.Join(got, "\n"), strings.Join([]string{
+		"push    1 main.plaid(1:10)",
+		"store   a       0       0 main.plaid(1:1)",
+		"load    a       0       0 main.plaid(2:10)",
+		"push    2 main.plaid(2:14)",
+		"add main.plaid(2:12)",
+		"store   b       0       1 main.plaid(2:1)",
+	}, "\n"))
+}
+
+func TestCompileVariableSlots(t *testing.T) {
+	btc := compileProgram(t, "main.plaid", `
+		let a := 1;
+		let f := fn (x: Int, y: Int): Int {
+			let z := x;
+			if true {
+				let w := x;
+				let x := y;
+				return a + z + w + x;
+			};
+			return 0;
+		};`, nil, OptNone).bytecode
+
+	var fn *ObjectFunction
+	for _, instr := range btc.Instructions {
+		if push, ok := instr.(InstrPush); ok {
+			fn, _ = push.Val.(*ObjectFunction)
+		}
+	}
+
+	var got []string
+	for _, instr := range fn.bytecode.Instructions {
+		switch instr.(type) {
+		case InstrReserve, InstrLoad, InstrStore:
+			got = append(got, instr.String())
+		}
+	}
+
+	expectString(t, strings.Join(got, "\n"), strings.Join([]string{
+		"alloc   z",
+		"load    x       0       0",
+		"store   z       0       2",
+		"alloc   w",
+		"alloc   x",
+		"load    x       1       0",
+		"store   w       0       0",
+		"load    y       1       1",
+		"store   x       0       1",
+		"load    a       2       0",
+		"load    z       1       2",
+		"load    w       0       0",
+		"load    x       0       1",
+	}, "\n"))
+}
+
+func TestRunGenericFunctions(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		let apply := fn<T, U>(x: T, f: (T) => U): [U] { return [f(x), f(x)]; };
+		let id := fn<T>(x: T): T { return x; };
+		io.print(id(42));
+		io.print(id("abc"));
+		io.print(apply(3, fn (n: Int): Int { return n * 2; }));`, "42", `"abc"`, "[6, 6]")
+}
+
+func TestRunMatchExpr(t *testing.T) {
+	expectOutput(t, `
+		use "io";
+		enum Shape { Circle(Int) Rect(Int, Int) Empty };
+		let area := fn (s: Shape): Int {
+			return match s {
+				Circle(r) => 3 * r * r,
+				Rect(w, h) => w * h,
+				Empty => 0,
+			};
+		};
+		let w := 100;
+		io.print(area(Shape.Circle(2)));
+		io.print(area(Shape.Rect(3, 4)));
+		io.print(area(Shape.Empty));
+		io.print(w);
+		io.print(Shape.Rect(1, 2));
+		io.print(Shape.Rect(1, 2) == Shape.Rect(1, 2));
+		io.print(Shape.Rect(1, 2) == Shape.Rect(2, 1));
+		let kind := fn (s: Shape): Str {
+			return match s { Empty => "empty" else => "shape" };
+		};
+		io.print(kind(Shape.Empty));
+		io.print(kind(Shape.Circle(1)));
+		let adders := match Shape.Circle(5) {
+			Circle(n) => fn (x: Int): Int { return x + n; },
+			else => fn (x: Int): Int { return x; },
+		};
+		io.print(adders(1));`,
+		"12", "12", "0", "100", "Rect(1, 2)", "true", "false", `"empty"`, `"shape"`, "6")
+}
+
+// runProgram parses, links, checks, compiles, and evaluates a program with an
+// "io" library whose print function records each printed value.
+func runProgram(t *testing.T, src string) ([]string, error) {
+	t.Helper()
+	return runProgramAt(t, src, OptNone)
+}
+
+func runProgramAt(t *testing.T, src string, level OptLevel) ([]string, error) {
+	t.Helper()
+	var out []string
+	stdlib := map[string]Module{"io": makePrintLibrary(&out)}
+	mod := compileProgram(t, "", src, stdlib, level)
+	err := Run(mod)
+	return out, err
+}
+
+// expectOutput runs the program with and without optimizations, both should
+// produce the same output
+func expectOutput(t *testing.T, src string, exp ...string) {
+	t.Helper()
+	for _, level := range []OptLevel{OptNone, OptBasic} {
+		got, err := runProgramAt(t, src, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+			t.Errorf("Expected output %v at level %d, got %v", exp, level, got)
+		}
+	}
+}
+
+func expectRuntimeError(t *testing.T, src string, exp string) {
+	t.Helper()
+	for _, level := range []OptLevel{OptNone, OptBasic} {
+		if _, err := runProgramAt(t, src, level); err == nil {
+			t.Errorf("Expected runtime error '%s' at level %d, got no error", exp, level)
+		} else if err.Error() != exp {
+			t.Errorf("Expected runtime error '%s' at level %d, got '%s'", exp, level, err)
+		}
+	}
+}
+
+func BenchmarkRunLoop(b *testing.B) {
+	benchmarkProgram(b, `
+		let i := 0;
+		let total := 0;
+		while i < 10000 {
+			total := total + i % 7;
+			i := i + 1;
+		};`)
+}
+
+func BenchmarkRunRecursion(b *testing.B) {
+	benchmarkProgram(b, `
+		let fib := fn (n: Int): Int {
+			if n < 2 {
+				return n;
+			};
+			return self(n - 1) + self(n - 2);
+		};
+		fib(18);`)
+}
+
+func BenchmarkRunClosures(b *testing.B) {
+	benchmarkProgram(b, `
+		let count := 0;
+		let add := fn (n: Int): Void {
+			count := count + n;
+		};
+		let i := 0;
+		while i < 5000 {
+			if i % 2 == 0 {
+				let step := i * 2;
+				add(step);
+			};
+			i := i + 1;
+		};`)
+}
+
+func benchmarkProgram(b *testing.B, src string) {
+	mod := compileProgram(b, "", src, nil, OptNone)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Run(mod); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
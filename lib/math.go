@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"plaid/lang"
+	"plaid/lang/types"
+)
+
+func Math() *lang.Library {
+	lib := lang.MakeLibrary("math")
+
+	lib.Function("toFloat", types.Function{
+		Params: types.Tuple{Children: []types.Type{
+			types.BuiltinInt,
+		}},
+		Ret: types.BuiltinFloat,
+	}, func(args []lang.Object) (lang.Object, error) {
+		if len(args) != 1 {
+			err := fmt.Errorf("wanted 1 argument, got %d", len(args))
+			return lang.ObjectNone{}, err
+		}
+
+		val := args[0].Value().(int64)
+		return lang.MakeFloat(float64(val)), nil
+	})
+
+	// toInt truncates toward zero
+	lib.Function("toInt", types.Function{
+		Params: types.Tuple{Children: []types.Type{
+			types.BuiltinFloat,
+		}},
+		Ret: types.BuiltinInt,
+	}, func(args []lang.Object) (lang.Object, error) {
+		if len(args) != 1 {
+			err := fmt.Errorf("wanted 1 argument, got %d", len(args))
+			return lang.ObjectNone{}, err
+		}
+
+		val := args[0].Value().(float64)
+		if math.IsNaN(val) || val >= math.MaxInt64 || val < math.MinInt64 {
+			err := fmt.Errorf("cannot convert %v to Int", val)
+			return lang.ObjectNone{}, err
+		}
+
+		return lang.MakeInt(int64(val)), nil
+	})
+
+	return lib
+}
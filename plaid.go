@@ -1,18 +1,39 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"plaid/lang"
 	"plaid/lib"
 )
 
 func main() {
-	if len(os.Args[1:]) >= 1 {
-		if errs := run(os.Args[1]); len(errs) > 0 {
+	optimize := flag.Bool("O", false, "optimize the bytecode")
+	output := flag.String("o", "", "write the compiled module to a .plaidc file")
+	flag.Parse()
+
+	level := lang.OptNone
+	if *optimize {
+		level = lang.OptBasic
+	}
+
+	if flag.NArg() >= 1 {
+		var errs []error
+		if filepath.Ext(flag.Arg(0)) == ".plaidc" {
+			errs = runCompiled(flag.Arg(0))
+		} else {
+			errs = run(flag.Arg(0), level, *output)
+		}
+
+		if len(errs) > 0 {
 			for _, err := range errs {
 				fmt.Fprintln(os.Stderr, err.Error())
+				if rterr, ok := err.(lang.RuntimeError); ok {
+					fmt.Fprintln(os.Stderr, rterr.StackTrace())
+				}
 			}
 			os.Exit(1)
 		}
@@ -27,7 +48,14 @@ func read(filename string) (src string, errs []error) {
 	}
 }
 
-func run(filename string) (errs []error) {
+func stdlib() map[string]lang.Module {
+	stdlib := make(map[string]lang.Module)
+	stdlib["io"] = lib.IO().Module("io")
+	stdlib["math"] = lib.Math().Module("math")
+	return stdlib
+}
+
+func run(filename string, level lang.OptLevel, output string) (errs []error) {
 	var src string
 	var ast *lang.AST
 	var mod lang.Module
@@ -47,10 +75,7 @@ func run(filename string) (errs []error) {
 		fmt.Println(ast)
 	}
 
-	stdlib := make(map[string]lang.Module)
-	stdlib["io"] = lib.IO().Module("io")
-
-	if mod, errs = lang.Link(filename, ast, stdlib); len(errs) > 0 {
+	if mod, errs = lang.Link(filename, ast, stdlib()); len(errs) > 0 {
 		return errs
 	}
 
@@ -62,11 +87,34 @@ func run(filename string) (errs []error) {
 	}
 
 	fmt.Println("\n=== BYTECODE")
-	btc = lang.Compile(mod)
-	fmt.Println(btc.String())
+	btc = lang.Compile(mod, level)
+	fmt.Println(btc.Disassemble())
+
+	if output != "" {
+		if err := lang.WriteFile(output, mod); err != nil {
+			return []error{err}
+		}
+	}
 
 	fmt.Println("\n=== OUTPUT")
-	lang.Run(mod.(*lang.ModuleVirtual))
+	if err := lang.Run(mod.(*lang.ModuleVirtual)); err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
+
+// runCompiled runs a module loaded from a .plaidc file without parsing or
+// checking any source code
+func runCompiled(filename string) (errs []error) {
+	mod, err := lang.LoadFile(filename, stdlib())
+	if err != nil {
+		return []error{err}
+	}
+
+	if err := lang.Run(mod); err != nil {
+		return []error{err}
+	}
 
 	return nil
 }